@@ -7,7 +7,9 @@ import (
 	"github.com/enneket/kratos-cli-boost/internal/biz"
 	"github.com/enneket/kratos-cli-boost/internal/client"
 	"github.com/enneket/kratos-cli-boost/internal/data"
+	"github.com/enneket/kratos-cli-boost/internal/mock"
 	"github.com/enneket/kratos-cli-boost/internal/server"
+	"github.com/enneket/kratos-cli-boost/internal/watch"
 
 	"github.com/spf13/cobra"
 )
@@ -32,6 +34,8 @@ func init() {
 	protoCmd.AddCommand(server.CmdServer)
 	protoCmd.AddCommand(biz.CmdBiz)
 	protoCmd.AddCommand(data.CmdData)
+	protoCmd.AddCommand(mock.CmdMock)
+	protoCmd.AddCommand(watch.CmdWatch)
 }
 
 func Execute() {
@@ -2,11 +2,11 @@ package add
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
+	"github.com/enneket/kratos-cli-boost/internal/base"
+
 	"github.com/spf13/cobra"
-	"golang.org/x/mod/modfile"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -15,32 +15,82 @@ import (
 var CmdAdd = &cobra.Command{
 	Use:   "add",
 	Short: "Add a proto API template",
-	Long:  "Add a proto API template. Example: kratos proto add helloworld/v1/hello.proto",
+	Long:  "Add a proto API template. Example: kratos proto add helloworld/v1/hello.proto --resource=Greeter --fields=\"name:string,age:int32,tags:repeated string\"",
 	Run:   run,
 }
 
+var (
+	resource     string
+	fields       string
+	kind         string
+	templateFile string
+	rpc          string
+	message      string
+	service      string
+)
+
+func init() {
+	CmdAdd.Flags().StringVarP(&resource, "resource", "r", "", "resource name, defaults to the service name")
+	CmdAdd.Flags().StringVarP(&fields, "fields", "f", "", "resource fields, e.g. \"name:string,age:int32,tags:repeated string\"")
+	CmdAdd.Flags().StringVarP(&kind, "kind", "k", kindCRUD, "template kind: "+strings.Join(kinds(), ", "))
+	CmdAdd.Flags().StringVar(&templateFile, "template", "", "custom proto template file, overrides --kind")
+	CmdAdd.Flags().StringVar(&rpc, "rpc", "", "add an rpc with its request/reply messages to an existing proto")
+	CmdAdd.Flags().StringVar(&message, "message", "", "add a message (with --fields) to an existing proto")
+	CmdAdd.Flags().StringVar(&service, "service", "", "service the rpc is added to, required when the proto has several services")
+}
+
 func run(_ *cobra.Command, args []string) {
 	if len(args) == 0 {
 		fmt.Println("Please enter the proto file or directory")
 		return
 	}
 	input := args[0]
-	n := strings.LastIndex(input, "/")
-	if n == -1 {
-		fmt.Println("The proto path needs to be hierarchical.")
+	if rpc != "" || message != "" {
+		if err := insert(input); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	path, fileName, err := splitProtoPath(input)
+	if err != nil {
+		fmt.Println(err)
 		return
 	}
-	path := input[:n]
-	fileName := input[n+1:]
 	pkgName := strings.ReplaceAll(path, "/", ".")
+	fs, err := parseFields(fields)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	res := serviceName(fileName)
+	if resource != "" {
+		res = toUpperCamelCase(resource)
+	}
+	if err = validateIdent("service", serviceName(fileName)); err == nil {
+		err = validateIdent("resource", res)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	goPkg, err := goPackage(path)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	p := &Proto{
 		Name:        fileName,
 		Path:        path,
 		Package:     pkgName,
-		GoPackage:   goPackage(path),
+		GoPackage:   goPkg,
 		JavaPackage: javaPackage(pkgName),
 		Service:     serviceName(fileName),
+		Resource:    res,
+		Fields:      fs,
+
+		Kind:         kind,
+		TemplateFile: templateFile,
 	}
 	if err := p.Generate(); err != nil {
 		fmt.Println(err)
@@ -48,19 +98,42 @@ func run(_ *cobra.Command, args []string) {
 	}
 }
 
-func modName() string {
-	modBytes, err := os.ReadFile("go.mod")
-	if err != nil {
-		if modBytes, err = os.ReadFile("../go.mod"); err != nil {
-			return ""
+func insert(file string) error {
+	in := &Insertion{
+		File:    file,
+		Service: service,
+		RPC:     toUpperCamelCase(rpc),
+		Message: toUpperCamelCase(message),
+	}
+	if in.RPC != "" {
+		if err := validateIdent("rpc", in.RPC); err != nil {
+			return err
 		}
 	}
-	return modfile.ModulePath(modBytes)
+	if in.Message != "" {
+		if err := validateIdent("message", in.Message); err != nil {
+			return err
+		}
+	}
+	if message != "" {
+		fs, err := parseFieldList(fields)
+		if err != nil {
+			return err
+		}
+		in.Fields = fs
+	}
+	return in.Apply()
 }
 
-func goPackage(path string) string {
+// goPackage returns the go_package option of the proto in path,
+// computed relative to the root of the module containing it.
+func goPackage(path string) (string, error) {
+	pkg, err := base.ImportPath(path)
+	if err != nil {
+		return "", err
+	}
 	s := strings.Split(path, "/")
-	return modName() + "/" + path + ";" + s[len(s)-1]
+	return pkg + ";" + s[len(s)-1], nil
 }
 
 func javaPackage(name string) string {
@@ -76,3 +149,10 @@ func toUpperCamelCase(s string) string {
 	s = cases.Title(language.Und, cases.NoLower).String(s)
 	return strings.ReplaceAll(s, " ", "")
 }
+
+func toLowerCamelCase(s string) string {
+	if len(s) > 0 {
+		s = strings.ToLower(s[:1]) + s[1:]
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package add
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	identRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	snakeRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// Field is a field of the resource message.
+type Field struct {
+	Name     string
+	Type     string
+	Repeated bool
+	Number   int
+}
+
+var scalarTypes = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+	"bool": true, "string": true, "bytes": true,
+}
+
+// wellKnownImports maps well-known types to the file that defines them.
+var wellKnownImports = map[string]string{
+	"google.protobuf.Timestamp": "google/protobuf/timestamp.proto",
+	"google.protobuf.Duration":  "google/protobuf/duration.proto",
+	"google.protobuf.Struct":    "google/protobuf/struct.proto",
+	"google.protobuf.Any":       "google/protobuf/any.proto",
+}
+
+// parseFields parses the resource fields.
+// The resource always starts with the AIP "name" field; an explicit string name field is merged into it.
+func parseFields(s string) ([]*Field, error) {
+	list, err := parseFieldList(s)
+	if err != nil {
+		return nil, err
+	}
+	fields := []*Field{{Name: "name", Type: "string", Number: 1}}
+	for _, f := range list {
+		if f.Name == "name" {
+			if f.Type != "string" || f.Repeated {
+				return nil, fmt.Errorf(`field "name" is the resource name and must be a string`)
+			}
+			continue
+		}
+		f.Number = len(fields) + 1
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// parseFieldList parses a field list like "name:string,age:int32,tags:repeated string".
+func parseFieldList(s string) ([]*Field, error) {
+	var fields []*Field
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, typ, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q, expected name:type", item)
+		}
+		f := &Field{Name: strings.TrimSpace(name), Type: strings.TrimSpace(typ)}
+		if rest, ok := strings.CutPrefix(f.Type, "repeated "); ok {
+			f.Repeated = true
+			f.Type = strings.TrimSpace(rest)
+		}
+		if !snakeRegexp.MatchString(f.Name) {
+			return nil, fmt.Errorf("invalid field name %q, expected lower_snake_case", f.Name)
+		}
+		if !isFieldType(f.Type) {
+			return nil, fmt.Errorf("invalid type %q of field %q", f.Type, f.Name)
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("duplicate field %q", f.Name)
+		}
+		seen[f.Name] = true
+		f.Number = len(fields) + 1
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func isFieldType(t string) bool {
+	if scalarTypes[t] {
+		return true
+	}
+	for _, s := range strings.Split(t, ".") {
+		if !identRegexp.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// toSnakeCase converts UserProfile to user_profile.
+func toSnakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// plural returns the naive english plural of a lower_snake_case word.
+func plural(s string) string {
+	switch {
+	case strings.HasSuffix(s, "y") && !strings.HasSuffix(s, "ay") && !strings.HasSuffix(s, "ey") && !strings.HasSuffix(s, "oy"):
+		return s[:len(s)-1] + "ies"
+	case strings.HasSuffix(s, "s"), strings.HasSuffix(s, "x"), strings.HasSuffix(s, "ch"), strings.HasSuffix(s, "sh"):
+		return s + "es"
+	}
+	return s + "s"
+}
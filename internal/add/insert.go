@@ -0,0 +1,351 @@
+package add
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+const annotationsImport = "google/api/annotations.proto"
+
+// Insertion adds an RPC or a message to an existing proto file.
+// The file is edited in place so that comments and formatting are preserved.
+type Insertion struct {
+	File    string
+	Service string
+	RPC     string
+	Message string
+	Fields  []*Field
+}
+
+type edit struct {
+	offset int
+	text   string
+}
+
+// Apply inserts the RPC and/or message into the proto file.
+func (in *Insertion) Apply() error {
+	src, err := os.ReadFile(in.File)
+	if err != nil {
+		return err
+	}
+	definition, err := proto.NewParser(bytes.NewReader(src)).Parse()
+	if err != nil {
+		return err
+	}
+	var (
+		pkg      string
+		imports  []*proto.Import
+		services []*proto.Service
+		messages = make(map[string]bool)
+	)
+	proto.Walk(definition,
+		proto.WithPackage(func(p *proto.Package) { pkg = p.Name }),
+		proto.WithImport(func(i *proto.Import) { imports = append(imports, i) }),
+		proto.WithService(func(s *proto.Service) { services = append(services, s) }),
+		proto.WithMessage(func(m *proto.Message) { messages[m.Name] = true }),
+	)
+
+	var (
+		edits   []edit
+		tail    []string
+		missing []string
+		indent  = indentOf(src)
+	)
+	if in.RPC != "" {
+		s, err := in.service(services)
+		if err != nil {
+			return err
+		}
+		for _, e := range s.Elements {
+			if r, ok := e.(*proto.RPC); ok && r.Name == in.RPC {
+				return fmt.Errorf("rpc %s already exists in service %s", in.RPC, s.Name)
+			}
+		}
+		req, reply := in.RPC+"Request", in.RPC+"Reply"
+		for _, m := range []string{req, reply} {
+			if messages[m] {
+				return fmt.Errorf("message %s already exists", m)
+			}
+		}
+		end, err := closingBrace(src, s.Position.Offset)
+		if err != nil {
+			return fmt.Errorf("service %s: %v", s.Name, err)
+		}
+		rule := httpRuleOf(in.RPC, version(pkg))
+		text := rpcText(in.RPC, rule, indent)
+		if start := lineStart(src, end); len(bytes.TrimSpace(src[start:end])) == 0 {
+			edits = append(edits, edit{offset: start, text: text})
+		} else {
+			// the closing brace shares its line with other elements
+			edits = append(edits, edit{offset: end, text: "\n" + text})
+		}
+		if !hasImport(imports, annotationsImport) {
+			missing = append(missing, annotationsImport)
+		}
+		tail = append(tail, requestText(req, rule, indent), messageText(reply, nil, indent))
+	}
+	if in.Message != "" {
+		if messages[in.Message] || (in.RPC != "" && (in.Message == in.RPC+"Request" || in.Message == in.RPC+"Reply")) {
+			return fmt.Errorf("message %s already exists", in.Message)
+		}
+		tail = append(tail, messageText(in.Message, in.Fields, indent))
+		for _, f := range in.Fields {
+			if imp, ok := wellKnownImports[f.Type]; ok && !hasImport(imports, imp) && !slices.Contains(missing, imp) {
+				missing = append(missing, imp)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		edits = append(edits, importEdit(src, imports, definition, missing))
+	}
+
+	out := applyEdits(src, edits)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	for _, t := range tail {
+		out = append(out, '\n')
+		out = append(out, t...)
+	}
+	return os.WriteFile(in.File, out, 0o644)
+}
+
+// service returns the service the RPC is added to.
+func (in *Insertion) service(services []*proto.Service) (*proto.Service, error) {
+	if in.Service != "" {
+		for _, s := range services {
+			if s.Name == in.Service {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("service %s not found in %s", in.Service, in.File)
+	}
+	switch len(services) {
+	case 0:
+		return nil, fmt.Errorf("no service found in %s", in.File)
+	case 1:
+		return services[0], nil
+	}
+	names := make([]string, 0, len(services))
+	for _, s := range services {
+		names = append(names, s.Name)
+	}
+	return nil, fmt.Errorf("%s has multiple services (%s), please specify one with --service", in.File, strings.Join(names, ", "))
+}
+
+// httpRule is the google.api.http annotation of an RPC.
+type httpRule struct {
+	Method string
+	Path   string
+	Body   string
+	// Name reports whether the path binds the name field of the request.
+	Name bool
+}
+
+// httpRuleOf derives the HTTP annotation from the RPC name following AIP conventions,
+// e.g. GetBook → get /v1/{name=books/*}, ListBooks → get /v1/books, ArchiveBook → post /v1/{name=books/*}:archive.
+func httpRuleOf(rpc, version string) httpRule {
+	verb, noun := splitVerb(rpc)
+	if verb == "Batch" {
+		// batch methods act on the collection, e.g. BatchGetBooks → get /v1/books:batchGet
+		op, nouns := splitVerb(noun)
+		path := "/" + version + "/" + toLowerCamelCase(nouns) + ":batch" + op
+		if op == "Get" {
+			return httpRule{Method: "get", Path: path}
+		}
+		return httpRule{Method: "post", Path: path, Body: "*"}
+	}
+	collection := toLowerCamelCase(toUpperCamelCase(plural(toSnakeCase(noun))))
+	switch verb {
+	case "List", "Search":
+		collection = toLowerCamelCase(noun)
+		if verb == "Search" {
+			return httpRule{Method: "get", Path: "/" + version + "/" + collection + ":search"}
+		}
+		return httpRule{Method: "get", Path: "/" + version + "/" + collection}
+	case "Create":
+		return httpRule{Method: "post", Path: "/" + version + "/" + collection, Body: "*"}
+	case "Get":
+		return httpRule{Method: "get", Path: "/" + version + "/{name=" + collection + "/*}", Name: true}
+	case "Update":
+		return httpRule{Method: "patch", Path: "/" + version + "/{name=" + collection + "/*}", Body: "*", Name: true}
+	case "Delete":
+		return httpRule{Method: "delete", Path: "/" + version + "/{name=" + collection + "/*}", Name: true}
+	}
+	if noun == "" {
+		return httpRule{Method: "post", Path: "/" + version + ":" + toLowerCamelCase(verb), Body: "*"}
+	}
+	return httpRule{Method: "post", Path: "/" + version + "/{name=" + collection + "/*}:" + toLowerCamelCase(verb), Body: "*", Name: true}
+}
+
+// splitVerb splits an RPC name into its leading verb and the rest, e.g. ArchiveBook → Archive, Book.
+func splitVerb(rpc string) (string, string) {
+	for i := 1; i < len(rpc); i++ {
+		if rpc[i] >= 'A' && rpc[i] <= 'Z' {
+			return rpc[:i], rpc[i:]
+		}
+	}
+	return rpc, ""
+}
+
+// version returns the API version of a proto package, e.g. helloworld.v1 → v1.
+func version(pkg string) string {
+	if v := path.Base(strings.ReplaceAll(pkg, ".", "/")); versionRegexp.MatchString(v) {
+		return v
+	}
+	return "v1"
+}
+
+func rpcText(name string, rule httpRule, indent string) string {
+	indent = indentUnit(indent)
+	inner := indent + indent
+	var b strings.Builder
+	fmt.Fprintf(&b, "%srpc %s (%sRequest) returns (%sReply) {\n", indent, name, name, name)
+	fmt.Fprintf(&b, "%soption (google.api.http) = {\n", inner)
+	fmt.Fprintf(&b, "%s%s%s: \"%s\"\n", inner, indent, rule.Method, rule.Path)
+	if rule.Body != "" {
+		fmt.Fprintf(&b, "%s%sbody: \"%s\"\n", inner, indent, rule.Body)
+	}
+	fmt.Fprintf(&b, "%s};\n", inner)
+	fmt.Fprintf(&b, "%s}\n", indent)
+	return b.String()
+}
+
+func requestText(name string, rule httpRule, indent string) string {
+	if rule.Name {
+		return messageText(name, []*Field{{Name: "name", Type: "string", Number: 1}}, indent)
+	}
+	return messageText(name, nil, indent)
+}
+
+func messageText(name string, fields []*Field, indent string) string {
+	if len(fields) == 0 {
+		return fmt.Sprintf("message %s {}\n", name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "message %s {\n", name)
+	for _, f := range fields {
+		b.WriteString(indentUnit(indent))
+		if f.Repeated {
+			b.WriteString("repeated ")
+		}
+		fmt.Fprintf(&b, "%s %s = %d;\n", f.Type, f.Name, f.Number)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// indentOf returns the indentation unit used by the file, tabs by default.
+func indentOf(src []byte) string {
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+	return "\t"
+}
+
+func indentUnit(indent string) string {
+	if strings.HasPrefix(indent, "\t") {
+		return "\t"
+	}
+	return indent
+}
+
+func hasImport(imports []*proto.Import, name string) bool {
+	for _, i := range imports {
+		if i.Filename == name {
+			return true
+		}
+	}
+	return false
+}
+
+// importEdit inserts the missing imports after the last import,
+// or after the package statement when the file has no imports.
+func importEdit(src []byte, imports []*proto.Import, definition *proto.Proto, missing []string) edit {
+	var b strings.Builder
+	for _, name := range missing {
+		fmt.Fprintf(&b, "import %q;\n", name)
+	}
+	text := b.String()
+	if len(imports) > 0 {
+		last := imports[len(imports)-1]
+		return edit{offset: lineEnd(src, last.Position.Offset), text: text}
+	}
+	for _, e := range definition.Elements {
+		if p, ok := e.(*proto.Package); ok {
+			return edit{offset: lineEnd(src, p.Position.Offset), text: "\n" + text}
+		}
+	}
+	for _, e := range definition.Elements {
+		if s, ok := e.(*proto.Syntax); ok {
+			return edit{offset: lineEnd(src, s.Position.Offset), text: "\n" + text}
+		}
+	}
+	return edit{offset: 0, text: text}
+}
+
+func applyEdits(src []byte, edits []edit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].offset > edits[j].offset })
+	out := append([]byte(nil), src...)
+	for _, e := range edits {
+		out = append(out[:e.offset], append([]byte(e.text), out[e.offset:]...)...)
+	}
+	return out
+}
+
+// lineStart returns the offset of the beginning of the line containing offset.
+func lineStart(src []byte, offset int) int {
+	return bytes.LastIndexByte(src[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset just after the newline ending the line containing offset.
+func lineEnd(src []byte, offset int) int {
+	if i := bytes.IndexByte(src[offset:], '\n'); i != -1 {
+		return offset + i + 1
+	}
+	return len(src)
+}
+
+// closingBrace returns the offset of the brace closing the block that starts after offset,
+// skipping comments and string literals.
+func closingBrace(src []byte, offset int) (int, error) {
+	depth := 0
+	for i := offset; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end == -1 {
+				return 0, fmt.Errorf("unterminated comment")
+			}
+			i += end + 3
+		case c == '"' || c == '\'':
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("closing brace not found")
+}
@@ -0,0 +1,219 @@
+package add
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRuleOf(t *testing.T) {
+	tests := []struct {
+		rpc  string
+		want httpRule
+	}{
+		{rpc: "GetBook", want: httpRule{Method: "get", Path: "/v1/{name=books/*}", Name: true}},
+		{rpc: "ListBooks", want: httpRule{Method: "get", Path: "/v1/books"}},
+		{rpc: "SearchBooks", want: httpRule{Method: "get", Path: "/v1/books:search"}},
+		{rpc: "CreateBook", want: httpRule{Method: "post", Path: "/v1/books", Body: "*"}},
+		{rpc: "UpdateBook", want: httpRule{Method: "patch", Path: "/v1/{name=books/*}", Body: "*", Name: true}},
+		{rpc: "DeleteBook", want: httpRule{Method: "delete", Path: "/v1/{name=books/*}", Name: true}},
+		{rpc: "GetLibraryCategory", want: httpRule{Method: "get", Path: "/v1/{name=libraryCategories/*}", Name: true}},
+		{rpc: "ArchiveBook", want: httpRule{Method: "post", Path: "/v1/{name=books/*}:archive", Body: "*", Name: true}},
+		{rpc: "BatchGetBooks", want: httpRule{Method: "get", Path: "/v1/books:batchGet"}},
+		{rpc: "BatchDeleteBooks", want: httpRule{Method: "post", Path: "/v1/books:batchDelete", Body: "*"}},
+		{rpc: "Ping", want: httpRule{Method: "post", Path: "/v1:ping", Body: "*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rpc, func(t *testing.T) {
+			if got := httpRuleOf(tt.rpc, "v1"); got != tt.want {
+				t.Errorf("httpRuleOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := map[string]string{
+		"helloworld.v1":      "v1",
+		"api.user.v2beta1":   "v2beta1",
+		"helloworld":         "v1",
+		"api.user.v1.public": "v1",
+	}
+	for pkg, want := range tests {
+		if got := version(pkg); got != want {
+			t.Errorf("version(%q) = %q, want %q", pkg, got, want)
+		}
+	}
+}
+
+const insertProto = `syntax = "proto3";
+
+package api.book.v1;
+
+import "google/protobuf/empty.proto";
+
+service Library {
+	// GetBook returns a book.
+	rpc GetBook (GetBookRequest) returns (GetBookReply);
+}
+
+message GetBookRequest {
+	string name = 1;
+}
+message GetBookReply {}
+`
+
+func TestInsertionApply(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		in   Insertion
+		want string
+		err  string
+	}{
+		{
+			name: "rpc",
+			src:  insertProto,
+			in:   Insertion{RPC: "DeleteBook"},
+			want: `syntax = "proto3";
+
+package api.book.v1;
+
+import "google/protobuf/empty.proto";
+import "google/api/annotations.proto";
+
+service Library {
+	// GetBook returns a book.
+	rpc GetBook (GetBookRequest) returns (GetBookReply);
+	rpc DeleteBook (DeleteBookRequest) returns (DeleteBookReply) {
+		option (google.api.http) = {
+			delete: "/v1/{name=books/*}"
+		};
+	}
+}
+
+message GetBookRequest {
+	string name = 1;
+}
+message GetBookReply {}
+
+message DeleteBookRequest {
+	string name = 1;
+}
+
+message DeleteBookReply {}
+`,
+		},
+		{
+			name: "message with well-known types",
+			src:  insertProto,
+			in: Insertion{Message: "Shelf", Fields: []*Field{
+				{Name: "created_at", Type: "google.protobuf.Timestamp", Number: 1},
+				{Name: "tags", Type: "string", Repeated: true, Number: 2},
+				{Name: "updated_at", Type: "google.protobuf.Timestamp", Number: 3},
+			}},
+			want: `syntax = "proto3";
+
+package api.book.v1;
+
+import "google/protobuf/empty.proto";
+import "google/protobuf/timestamp.proto";
+
+service Library {
+	// GetBook returns a book.
+	rpc GetBook (GetBookRequest) returns (GetBookReply);
+}
+
+message GetBookRequest {
+	string name = 1;
+}
+message GetBookReply {}
+
+message Shelf {
+	google.protobuf.Timestamp created_at = 1;
+	repeated string tags = 2;
+	google.protobuf.Timestamp updated_at = 3;
+}
+`,
+		},
+		{
+			name: "closing brace on the rpc line without imports",
+			src:  "syntax = \"proto3\";\npackage shop.v2;\nservice Shop { rpc Ping (PingRequest) returns (PingReply);}\n",
+			in:   Insertion{RPC: "ListOrders"},
+			want: `syntax = "proto3";
+package shop.v2;
+
+import "google/api/annotations.proto";
+service Shop { rpc Ping (PingRequest) returns (PingReply);
+	rpc ListOrders (ListOrdersRequest) returns (ListOrdersReply) {
+		option (google.api.http) = {
+			get: "/v2/orders"
+		};
+	}
+}
+
+message ListOrdersRequest {}
+
+message ListOrdersReply {}
+`,
+		},
+		{
+			name: "existing rpc",
+			src:  insertProto,
+			in:   Insertion{RPC: "GetBook"},
+			err:  "rpc GetBook already exists in service Library",
+		},
+		{
+			name: "existing message",
+			src:  insertProto,
+			in:   Insertion{Message: "GetBookReply"},
+			err:  "message GetBookReply already exists",
+		},
+		{
+			name: "message of the inserted rpc",
+			src:  insertProto,
+			in:   Insertion{RPC: "ListBooks", Message: "ListBooksRequest"},
+			err:  "message ListBooksRequest already exists",
+		},
+		{
+			name: "unknown service",
+			src:  insertProto,
+			in:   Insertion{Service: "Shop", RPC: "ListBooks"},
+			err:  "service Shop not found in ",
+		},
+		{
+			name: "multiple services",
+			src:  insertProto + "service Shop {}\n",
+			in:   Insertion{RPC: "ListBooks"},
+			err:  "has multiple services (Library, Shop), please specify one with --service",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "book.proto")
+			if err := os.WriteFile(file, []byte(tt.src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			in := tt.in
+			in.File = file
+			err := in.Apply()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Apply() error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Apply() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,15 @@ package add
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 )
 
+var versionRegexp = regexp.MustCompile(`^v\d+((alpha|beta)\d*)?$`)
+
 // Proto is a proto generator.
 type Proto struct {
 	Name        string
@@ -14,6 +20,62 @@ type Proto struct {
 	Package     string
 	GoPackage   string
 	JavaPackage string
+	Resource    string
+	Fields      []*Field
+
+	// Kind selects a built-in template, TemplateFile overrides it with a custom one.
+	Kind         string
+	TemplateFile string
+}
+
+// ResourceField returns the snake case field name of the resource, e.g. user_profile.
+func (p *Proto) ResourceField() string {
+	return toSnakeCase(p.Resource)
+}
+
+// ResourceFieldPlural returns the plural snake case field name of the resource, e.g. user_profiles.
+func (p *Proto) ResourceFieldPlural() string {
+	return plural(p.ResourceField())
+}
+
+// ResourcePlural returns the plural resource name, e.g. UserProfiles.
+func (p *Proto) ResourcePlural() string {
+	return toUpperCamelCase(p.ResourceFieldPlural())
+}
+
+// Collection returns the AIP collection identifier of the resource, e.g. userProfiles.
+func (p *Proto) Collection() string {
+	return toLowerCamelCase(p.ResourcePlural())
+}
+
+// Version returns the API version used in HTTP paths, taken from the proto path when possible.
+func (p *Proto) Version() string {
+	if v := path.Base(p.Path); versionRegexp.MatchString(v) {
+		return v
+	}
+	return "v1"
+}
+
+// ResourceConst returns the upper snake case resource name used by enum values, e.g. USER_PROFILE.
+func (p *Proto) ResourceConst() string {
+	return strings.ToUpper(p.ResourceField())
+}
+
+// Imports returns the base imports merged with the imports required by the resource fields.
+func (p *Proto) Imports(base ...string) []string {
+	imports := append([]string(nil), base...)
+	seen := make(map[string]bool)
+	for _, imp := range base {
+		seen[imp] = true
+	}
+	for _, f := range p.Fields {
+		if imp, ok := wellKnownImports[f.Type]; ok && !seen[imp] {
+			seen[imp] = true
+			imports = append(imports, imp)
+		}
+	}
+	sort.Strings(imports)
+	return imports
 }
 
 // Generate generate a proto template.
@@ -34,7 +96,7 @@ func (p *Proto) Generate() error {
 	}
 	name := filepath.Join(to, p.Name)
 	if _, err := os.Stat(name); !os.IsNotExist(err) {
-		return fmt.Errorf("%s already exists", p.Name)
+		return fmt.Errorf("%s already exists, use --rpc or --message to extend it", p.Name)
 	}
 	return os.WriteFile(name, body, 0o644)
 }
@@ -2,46 +2,367 @@ package add
 
 import (
 	"bytes"
+	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"text/template"
 )
 
+// Kinds of the built-in proto templates.
+const (
+	kindCRUD      = "crud"
+	kindStreaming = "streaming"
+	kindEvent     = "event"
+	kindAdmin     = "admin"
+	kindErrors    = "errors"
+	kindConfig    = "config"
+)
+
+var kindTemplates = map[string]string{
+	kindCRUD:      protoTemplate,
+	kindStreaming: streamingTemplate,
+	kindEvent:     eventTemplate,
+	kindAdmin:     adminTemplate,
+	kindErrors:    errorsTemplate,
+	kindConfig:    configTemplate,
+}
+
+// kinds returns the names of the built-in templates.
+func kinds() []string {
+	names := make([]string, 0, len(kindTemplates))
+	for k := range kindTemplates {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
 const protoTemplate = `
 syntax = "proto3";
 
 package {{.Package}};
 
+{{range .Imports "google/api/annotations.proto" "google/protobuf/field_mask.proto"}}import "{{.}}";
+{{end}}
+option go_package = "{{.GoPackage}}";
+option java_multiple_files = true;
+option java_package = "{{.JavaPackage}}";
+
+service {{.Service}} {
+	rpc Create{{.Resource}} (Create{{.Resource}}Request) returns ({{.Resource}}) {
+		option (google.api.http) = {
+			post: "/{{.Version}}/{{.Collection}}"
+			body: "{{.ResourceField}}"
+		};
+	}
+	rpc Update{{.Resource}} (Update{{.Resource}}Request) returns ({{.Resource}}) {
+		option (google.api.http) = {
+			patch: "/{{.Version}}/{{"{"}}{{.ResourceField}}.name={{.Collection}}/*}"
+			body: "{{.ResourceField}}"
+		};
+	}
+	rpc Delete{{.Resource}} (Delete{{.Resource}}Request) returns (Delete{{.Resource}}Reply) {
+		option (google.api.http) = {
+			delete: "/{{.Version}}/{name={{.Collection}}/*}"
+		};
+	}
+	rpc Get{{.Resource}} (Get{{.Resource}}Request) returns ({{.Resource}}) {
+		option (google.api.http) = {
+			get: "/{{.Version}}/{name={{.Collection}}/*}"
+		};
+	}
+	rpc List{{.ResourcePlural}} (List{{.ResourcePlural}}Request) returns (List{{.ResourcePlural}}Reply) {
+		option (google.api.http) = {
+			get: "/{{.Version}}/{{.Collection}}"
+		};
+	}
+}
+
+message {{.Resource}} {
+	{{- range .Fields}}
+	{{if .Repeated}}repeated {{end}}{{.Type}} {{.Name}} = {{.Number}};
+	{{- end}}
+}
+
+message Create{{.Resource}}Request {
+	{{.Resource}} {{.ResourceField}} = 1;
+}
+
+message Update{{.Resource}}Request {
+	{{.Resource}} {{.ResourceField}} = 1;
+	google.protobuf.FieldMask update_mask = 2;
+}
+
+message Delete{{.Resource}}Request {
+	string name = 1;
+}
+message Delete{{.Resource}}Reply {}
+
+message Get{{.Resource}}Request {
+	string name = 1;
+}
+
+message List{{.ResourcePlural}}Request {
+	int32 page_size = 1;
+	string page_token = 2;
+}
+message List{{.ResourcePlural}}Reply {
+	repeated {{.Resource}} {{.ResourceFieldPlural}} = 1;
+	string next_page_token = 2;
+}
+`
+
+const streamingTemplate = `
+syntax = "proto3";
+
+package {{.Package}};
+
+{{range .Imports "google/protobuf/field_mask.proto"}}import "{{.}}";
+{{end}}
+option go_package = "{{.GoPackage}}";
+option java_multiple_files = true;
+option java_package = "{{.JavaPackage}}";
+
+service {{.Service}} {
+	// Watch{{.ResourcePlural}} streams changes of {{.ResourceFieldPlural}} matching the filter.
+	rpc Watch{{.ResourcePlural}} (Watch{{.ResourcePlural}}Request) returns (stream Watch{{.ResourcePlural}}Reply);
+	// Sync{{.ResourcePlural}} exchanges {{.ResourceFieldPlural}} in both directions.
+	rpc Sync{{.ResourcePlural}} (stream Sync{{.ResourcePlural}}Request) returns (stream Sync{{.ResourcePlural}}Reply);
+}
+
+message {{.Resource}} {
+	{{- range .Fields}}
+	{{if .Repeated}}repeated {{end}}{{.Type}} {{.Name}} = {{.Number}};
+	{{- end}}
+}
+
+message Watch{{.ResourcePlural}}Request {
+	string filter = 1;
+}
+message Watch{{.ResourcePlural}}Reply {
+	enum EventType {
+		EVENT_TYPE_UNSPECIFIED = 0;
+		CREATED = 1;
+		UPDATED = 2;
+		DELETED = 3;
+	}
+	EventType type = 1;
+	{{.Resource}} {{.ResourceField}} = 2;
+}
+
+message Sync{{.ResourcePlural}}Request {
+	{{.Resource}} {{.ResourceField}} = 1;
+	google.protobuf.FieldMask update_mask = 2;
+}
+message Sync{{.ResourcePlural}}Reply {
+	{{.Resource}} {{.ResourceField}} = 1;
+}
+`
+
+const eventTemplate = `
+syntax = "proto3";
+
+package {{.Package}};
+
+{{range .Imports "google/protobuf/field_mask.proto" "google/protobuf/timestamp.proto"}}import "{{.}}";
+{{end}}
+option go_package = "{{.GoPackage}}";
+option java_multiple_files = true;
+option java_package = "{{.JavaPackage}}";
+
+message {{.Resource}} {
+	{{- range .Fields}}
+	{{if .Repeated}}repeated {{end}}{{.Type}} {{.Name}} = {{.Number}};
+	{{- end}}
+}
+
+// {{.Resource}}Event is the envelope published on the {{.ResourceField}} topic.
+message {{.Resource}}Event {
+	string id = 1;
+	string source = 2;
+	google.protobuf.Timestamp occur_time = 3;
+	oneof payload {
+		{{.Resource}}Created created = 4;
+		{{.Resource}}Updated updated = 5;
+		{{.Resource}}Deleted deleted = 6;
+	}
+}
+
+message {{.Resource}}Created {
+	{{.Resource}} {{.ResourceField}} = 1;
+}
+
+message {{.Resource}}Updated {
+	{{.Resource}} {{.ResourceField}} = 1;
+	google.protobuf.FieldMask update_mask = 2;
+}
+
+message {{.Resource}}Deleted {
+	string name = 1;
+}
+`
+
+const adminTemplate = `
+syntax = "proto3";
+
+package {{.Package}};
+
+{{range .Imports "google/api/annotations.proto"}}import "{{.}}";
+{{end}}
 option go_package = "{{.GoPackage}}";
 option java_multiple_files = true;
 option java_package = "{{.JavaPackage}}";
 
 service {{.Service}} {
-	rpc Create{{.Service}} (Create{{.Service}}Request) returns (Create{{.Service}}Reply);
-	rpc Update{{.Service}} (Update{{.Service}}Request) returns (Update{{.Service}}Reply);
-	rpc Delete{{.Service}} (Delete{{.Service}}Request) returns (Delete{{.Service}}Reply);
-	rpc Get{{.Service}} (Get{{.Service}}Request) returns (Get{{.Service}}Reply);
-	rpc List{{.Service}} (List{{.Service}}Request) returns (List{{.Service}}Reply);
+	rpc List{{.ResourcePlural}} (List{{.ResourcePlural}}Request) returns (List{{.ResourcePlural}}Reply) {
+		option (google.api.http) = {
+			get: "/admin/{{.Version}}/{{.Collection}}"
+		};
+	}
+	rpc BatchGet{{.ResourcePlural}} (BatchGet{{.ResourcePlural}}Request) returns (BatchGet{{.ResourcePlural}}Reply) {
+		option (google.api.http) = {
+			get: "/admin/{{.Version}}/{{.Collection}}:batchGet"
+		};
+	}
+	rpc BatchDelete{{.ResourcePlural}} (BatchDelete{{.ResourcePlural}}Request) returns (BatchDelete{{.ResourcePlural}}Reply) {
+		option (google.api.http) = {
+			post: "/admin/{{.Version}}/{{.Collection}}:batchDelete"
+			body: "*"
+		};
+	}
+	rpc Undelete{{.Resource}} (Undelete{{.Resource}}Request) returns ({{.Resource}}) {
+		option (google.api.http) = {
+			post: "/admin/{{.Version}}/{name={{.Collection}}/*}:undelete"
+			body: "*"
+		};
+	}
+	rpc Purge{{.ResourcePlural}} (Purge{{.ResourcePlural}}Request) returns (Purge{{.ResourcePlural}}Reply) {
+		option (google.api.http) = {
+			post: "/admin/{{.Version}}/{{.Collection}}:purge"
+			body: "*"
+		};
+	}
+}
+
+message {{.Resource}} {
+	{{- range .Fields}}
+	{{if .Repeated}}repeated {{end}}{{.Type}} {{.Name}} = {{.Number}};
+	{{- end}}
+}
+
+message List{{.ResourcePlural}}Request {
+	int32 page_size = 1;
+	string page_token = 2;
+	string filter = 3;
+	string order_by = 4;
+	bool show_deleted = 5;
+}
+message List{{.ResourcePlural}}Reply {
+	repeated {{.Resource}} {{.ResourceFieldPlural}} = 1;
+	string next_page_token = 2;
+	int32 total_size = 3;
+}
+
+message BatchGet{{.ResourcePlural}}Request {
+	repeated string names = 1;
+}
+message BatchGet{{.ResourcePlural}}Reply {
+	repeated {{.Resource}} {{.ResourceFieldPlural}} = 1;
+}
+
+message BatchDelete{{.ResourcePlural}}Request {
+	repeated string names = 1;
+}
+message BatchDelete{{.ResourcePlural}}Reply {}
+
+message Undelete{{.Resource}}Request {
+	string name = 1;
+}
+
+message Purge{{.ResourcePlural}}Request {
+	string filter = 1;
+	bool force = 2;
+}
+message Purge{{.ResourcePlural}}Reply {
+	int32 purge_count = 1;
+}
+`
+
+const errorsTemplate = `
+syntax = "proto3";
+
+package {{.Package}};
+
+import "errors/errors.proto";
+
+option go_package = "{{.GoPackage}}";
+option java_multiple_files = true;
+option java_package = "{{.JavaPackage}}";
+
+enum ErrorReason {
+	option (errors.default_code) = 500;
+
+	{{.ResourceConst}}_UNSPECIFIED = 0;
+	{{.ResourceConst}}_NOT_FOUND = 1 [(errors.code) = 404];
+	{{.ResourceConst}}_ALREADY_EXISTS = 2 [(errors.code) = 409];
+	{{.ResourceConst}}_INVALID_ARGUMENT = 3 [(errors.code) = 400];
+	{{.ResourceConst}}_PERMISSION_DENIED = 4 [(errors.code) = 403];
 }
+`
 
-message Create{{.Service}}Request {}
-message Create{{.Service}}Reply {}
+const configTemplate = `
+syntax = "proto3";
 
-message Update{{.Service}}Request {}
-message Update{{.Service}}Reply {}
+package {{.Package}};
 
-message Delete{{.Service}}Request {}
-message Delete{{.Service}}Reply {}
+import "google/protobuf/duration.proto";
 
-message Get{{.Service}}Request {}
-message Get{{.Service}}Reply {}
+option go_package = "{{.GoPackage}}";
 
-message List{{.Service}}Request {}
-message List{{.Service}}Reply {}
+message Bootstrap {
+	Server server = 1;
+	Data data = 2;
+}
+
+message Server {
+	message HTTP {
+		string network = 1;
+		string addr = 2;
+		google.protobuf.Duration timeout = 3;
+	}
+	message GRPC {
+		string network = 1;
+		string addr = 2;
+		google.protobuf.Duration timeout = 3;
+	}
+	HTTP http = 1;
+	GRPC grpc = 2;
+}
+
+message Data {
+	message Database {
+		string driver = 1;
+		string source = 2;
+	}
+	message Redis {
+		string network = 1;
+		string addr = 2;
+		google.protobuf.Duration read_timeout = 3;
+		google.protobuf.Duration write_timeout = 4;
+	}
+	Database database = 1;
+	Redis redis = 2;
+}
 `
 
 func (p *Proto) execute() ([]byte, error) {
+	text, err := p.template()
+	if err != nil {
+		return nil, err
+	}
 	buf := new(bytes.Buffer)
-	tmpl, err := template.New("proto").Parse(strings.TrimSpace(protoTemplate))
+	tmpl, err := template.New("proto").Parse(strings.TrimSpace(text))
 	if err != nil {
 		return nil, err
 	}
@@ -50,3 +371,23 @@ func (p *Proto) execute() ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// template returns the custom template file if set, otherwise the built-in template of the kind.
+func (p *Proto) template() (string, error) {
+	if p.TemplateFile != "" {
+		b, err := os.ReadFile(p.TemplateFile)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	kind := p.Kind
+	if kind == "" {
+		kind = kindCRUD
+	}
+	text, ok := kindTemplates[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown kind %q, available kinds: %s", p.Kind, strings.Join(kinds(), ", "))
+	}
+	return text, nil
+}
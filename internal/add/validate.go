@@ -0,0 +1,85 @@
+package add
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	segmentRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	camelRegexp   = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	invalidRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// splitProtoPath validates the proto path given to add and splits it into its directory and file name.
+// The errors carry a suggested correction whenever one can be derived.
+func splitProtoPath(input string) (string, string, error) {
+	if filepath.IsAbs(input) {
+		return "", "", fmt.Errorf("the proto path %q must be relative to the project root", input)
+	}
+	input = path.Clean(filepath.ToSlash(input))
+	dir, file := path.Split(input)
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" || dir == "." {
+		return "", "", fmt.Errorf("the proto path %q needs to be hierarchical, e.g. %s", input, suggestPath([]string{"api"}, file))
+	}
+	if strings.HasPrefix(dir, "..") {
+		return "", "", fmt.Errorf("the proto path %q must be inside the project", input)
+	}
+	segments := strings.Split(dir, "/")
+
+	if ext := path.Ext(file); ext != ".proto" {
+		return "", "", fmt.Errorf("the proto file %q must have the .proto extension, did you mean %s?", file, suggestPath(segments, file))
+	}
+	if name := strings.TrimSuffix(file, ".proto"); !segmentRegexp.MatchString(name) {
+		return "", "", fmt.Errorf("the proto file name %q must be lower_snake_case, did you mean %s?", file, suggestPath(segments, file))
+	}
+	for _, s := range segments {
+		if !segmentRegexp.MatchString(s) {
+			return "", "", fmt.Errorf("the package segment %q must be lowercase letters, digits and underscores, did you mean %s?", s, suggestPath(segments, file))
+		}
+	}
+	// the version is also the go package name, see goPackage
+	if !versionRegexp.MatchString(segments[len(segments)-1]) {
+		return "", "", fmt.Errorf("the proto package %q is not versioned, did you mean %s?", strings.Join(segments, "."), suggestPath(segments, file))
+	}
+	return dir, file, nil
+}
+
+// validateIdent checks the UpperCamelCase name of a generated service or resource.
+func validateIdent(kind, name string) error {
+	if !camelRegexp.MatchString(name) {
+		return fmt.Errorf("the %s name %q is not a valid identifier, did you mean %s?", kind, name, toUpperCamelCase(suggestSegment(name)))
+	}
+	return nil
+}
+
+// suggestPath returns a corrected proto path: lowercase snake case segments ending with a version.
+func suggestPath(segments []string, file string) string {
+	fixed := make([]string, 0, len(segments)+1)
+	for _, s := range segments {
+		if s = suggestSegment(s); s != "" {
+			fixed = append(fixed, s)
+		}
+	}
+	if len(fixed) == 0 {
+		fixed = append(fixed, "api")
+	}
+	if !versionRegexp.MatchString(fixed[len(fixed)-1]) {
+		fixed = append(fixed, "v1")
+	}
+	name := suggestSegment(strings.TrimSuffix(file, path.Ext(file)))
+	if name == "" {
+		name = "service"
+	}
+	return path.Join(append(fixed, name+".proto")...)
+}
+
+// suggestSegment converts a path segment to lower snake case, e.g. Foo-Bar → foo_bar.
+func suggestSegment(s string) string {
+	s = invalidRegexp.ReplaceAllString(toSnakeCase(s), "_")
+	return strings.Trim(strings.TrimLeft(s, "_0123456789"), "_")
+}
@@ -0,0 +1,82 @@
+package add
+
+import "testing"
+
+func TestSplitProtoPath(t *testing.T) {
+	tests := []struct {
+		input string
+		dir   string
+		file  string
+		err   string
+	}{
+		{input: "api/helloworld/v1/greeter.proto", dir: "api/helloworld/v1", file: "greeter.proto"},
+		{input: "./api/user/v1beta1/user_profile.proto", dir: "api/user/v1beta1", file: "user_profile.proto"},
+		{input: "/api/helloworld/v1/greeter.proto", err: `the proto path "/api/helloworld/v1/greeter.proto" must be relative to the project root`},
+		{input: "greeter.proto", err: `the proto path "greeter.proto" needs to be hierarchical, e.g. api/v1/greeter.proto`},
+		{input: "../api/v1/greeter.proto", err: `the proto path "../api/v1/greeter.proto" must be inside the project`},
+		{input: "api/helloworld/v1/greeter.txt", err: `the proto file "greeter.txt" must have the .proto extension, did you mean api/helloworld/v1/greeter.proto?`},
+		{input: "api/helloworld/v1/UserProfile.proto", err: `the proto file name "UserProfile.proto" must be lower_snake_case, did you mean api/helloworld/v1/user_profile.proto?`},
+		{input: "api/Hello-World/v1/greeter.proto", err: `the package segment "Hello-World" must be lowercase letters, digits and underscores, did you mean api/hello_world/v1/greeter.proto?`},
+		{input: "api/helloworld/greeter.proto", err: `the proto package "api.helloworld" is not versioned, did you mean api/helloworld/v1/greeter.proto?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			dir, file, err := splitProtoPath(tt.input)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("splitProtoPath() error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitProtoPath() error = %v", err)
+			}
+			if dir != tt.dir || file != tt.file {
+				t.Errorf("splitProtoPath() = %s, %s, want %s, %s", dir, file, tt.dir, tt.file)
+			}
+		})
+	}
+}
+
+func TestValidateIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  string
+	}{
+		{name: "Greeter"},
+		{name: "UserProfile2"},
+		{name: "userProfile", err: `the rpc name "userProfile" is not a valid identifier, did you mean UserProfile?`},
+		{name: "Get-User", err: `the rpc name "Get-User" is not a valid identifier, did you mean GetUser?`},
+		{name: "9Lives", err: `the rpc name "9Lives" is not a valid identifier, did you mean Lives?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIdent("rpc", tt.name)
+			if tt.err == "" && err != nil {
+				t.Fatalf("validateIdent() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("validateIdent() error = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSuggestSegment(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "helloworld", want: "helloworld"},
+		{in: "HelloWorld", want: "hello_world"},
+		{in: "hello-world", want: "hello_world"},
+		{in: "2fa", want: "fa"},
+		{in: "__x__", want: "x"},
+		{in: "---", want: ""},
+	}
+	for _, tt := range tests {
+		if got := suggestSegment(tt.in); got != tt.want {
+			t.Errorf("suggestSegment(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
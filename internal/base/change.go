@@ -0,0 +1,38 @@
+package base
+
+import (
+	"fmt"
+	"os"
+)
+
+// Change is a file handled by a layer generator.
+type Change struct {
+	File string
+	// Exists reports that the file already existed and was left untouched.
+	Exists bool
+	// Added lists the declarations merged into an existing file.
+	Added []string
+}
+
+// WriteGo writes the generated go file. An existing file is left untouched,
+// unless merge is set: the declarations it lacks are then added to it.
+func WriteGo(name string, generated []byte, merge bool) (*Change, error) {
+	existing, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return &Change{File: name}, os.WriteFile(name, generated, 0o644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !merge {
+		return &Change{File: name, Exists: true}, nil
+	}
+	merged, added, err := MergeGo(existing, generated)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(added) == 0 {
+		return &Change{File: name, Exists: true}, nil
+	}
+	return &Change{File: name, Added: added}, os.WriteFile(name, merged, 0o644)
+}
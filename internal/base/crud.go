@@ -0,0 +1,220 @@
+package base
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/emicklei/proto"
+)
+
+// The verbs of the standard methods, prefixing the rpc names, e.g. CreateUser.
+const (
+	VerbCreate = "Create"
+	VerbGet    = "Get"
+	VerbUpdate = "Update"
+	VerbDelete = "Delete"
+	VerbList   = "List"
+)
+
+// MethodVerb returns the standard method verb prefixing the rpc name, or "" if the rpc is not a standard method.
+func MethodVerb(name string) string {
+	for _, verb := range []string{VerbCreate, VerbGet, VerbUpdate, VerbDelete, VerbList} {
+		rest, ok := strings.CutPrefix(name, verb)
+		if ok && rest != "" && unicode.IsUpper(rune(rest[0])) {
+			return verb
+		}
+	}
+	return ""
+}
+
+// Writes reports whether the rpc is a standard method that modifies the resources: Create, Update or Delete.
+func Writes(name string) bool {
+	switch MethodVerb(name) {
+	case VerbCreate, VerbUpdate, VerbDelete:
+		return true
+	}
+	return false
+}
+
+// columnTypes are the go types of the proto types stored in table columns.
+var columnTypes = map[string]string{
+	"double":   "float64",
+	"float":    "float32",
+	"int32":    "int32",
+	"sint32":   "int32",
+	"sfixed32": "int32",
+	"int64":    "int64",
+	"sint64":   "int64",
+	"sfixed64": "int64",
+	"uint32":   "uint32",
+	"fixed32":  "uint32",
+	"uint64":   "uint64",
+	"fixed64":  "uint64",
+	"bool":     "bool",
+	"string":   "string",
+	"bytes":    "[]byte",
+
+	"google.protobuf.Timestamp": "time.Time",
+}
+
+// Resource is a message stored in a table by the standard methods of a service,
+// identified by its id field.
+type Resource struct {
+	Type    string    // message name, e.g. User
+	Table   string    // table name, the plural of the message name in snake case, e.g. users
+	Key     *Column   // id field, the primary key of the table
+	Columns []*Column // singular scalar, enum and timestamp fields stored in the table, the key first
+}
+
+// Updatable returns the columns written by Update, all but the key.
+func (r *Resource) Updatable() []*Column {
+	return r.Columns[1:]
+}
+
+// Column is a field of a resource stored in a column of its table.
+type Column struct {
+	Field  string // proto field name, e.g. create_time
+	Name   string // column name, e.g. create_time
+	GoType string // go type of the field in the biz entity, e.g. time.Time
+}
+
+// Standard is a standard method of a resource: a Create, Get, Update, Delete or List rpc.
+type Standard struct {
+	Verb     string
+	Resource *Resource
+	Field    string // request field holding the resource for Create and Update, empty if the request is the resource
+	Items    string // reply field holding the resources for List
+}
+
+// StandardMethod returns the standard method implemented by the rpc, or nil.
+// The resource is the reply of Create, Get and Update, the message named after the rpc for Delete,
+// e.g. User for DeleteUser, and the type of the repeated field of the reply for List.
+// Create and Update take the resource or a request with a resource field, Get and Delete a request with
+// the id of the resource.
+func StandardMethod(definition *proto.Proto, rpc *proto.RPC) *Standard {
+	verb := MethodVerb(rpc.Name)
+	if verb == "" {
+		return nil
+	}
+	messages := make(map[string]*proto.Message)
+	enums := make(map[string]bool)
+	proto.Walk(definition,
+		proto.WithMessage(func(m *proto.Message) {
+			messages[m.Name] = m
+		}),
+		proto.WithEnum(func(e *proto.Enum) {
+			enums[e.Name] = true
+		}),
+	)
+	request, reply := typeName(rpc.RequestType), typeName(rpc.ReturnsType)
+	s := &Standard{Verb: verb}
+	switch verb {
+	case VerbCreate, VerbUpdate:
+		s.Resource = newResource(messages[reply], enums)
+		if s.Resource == nil {
+			return nil
+		}
+		if request == reply {
+			return s
+		}
+		for _, f := range fields(messages[request]) {
+			if !f.Repeated && typeName(f.Type) == reply {
+				s.Field = f.Name
+				return s
+			}
+		}
+		return nil
+	case VerbGet:
+		s.Resource = newResource(messages[reply], enums)
+	case VerbDelete:
+		s.Resource = newResource(messages[strings.TrimPrefix(rpc.Name, verb)], enums)
+	case VerbList:
+		m := messages[reply]
+		if m == nil {
+			return nil
+		}
+		for _, e := range m.Elements {
+			if f, ok := e.(*proto.NormalField); ok && f.Repeated {
+				s.Resource = newResource(messages[typeName(f.Type)], enums)
+				s.Items = f.Name
+				break
+			}
+		}
+		if s.Resource == nil {
+			return nil
+		}
+		return s
+	}
+	if s.Resource == nil {
+		return nil
+	}
+	if id := fields(messages[request])["id"]; id == nil || id.Repeated || columnTypes[id.Type] != s.Resource.Key.GoType {
+		return nil
+	}
+	return s
+}
+
+// newResource returns the resource stored from the message, or nil if the message has no string or integer
+// id field or no other field to store.
+func newResource(m *proto.Message, enums map[string]bool) *Resource {
+	if m == nil || strings.HasSuffix(m.Name, "Request") || strings.HasSuffix(m.Name, "Reply") {
+		return nil
+	}
+	r := &Resource{Type: m.Name, Table: plural(ColumnName(m.Name))}
+	for _, e := range m.Elements {
+		f, ok := e.(*proto.NormalField)
+		if !ok || f.Repeated {
+			continue
+		}
+		t := columnTypes[strings.TrimPrefix(f.Type, ".")]
+		if enums[typeName(f.Type)] {
+			t = "int32"
+		}
+		if t == "" {
+			continue
+		}
+		c := &Column{Field: f.Name, Name: ColumnName(f.Name), GoType: t}
+		if f.Name == "id" {
+			r.Key = c
+			r.Columns = append([]*Column{c}, r.Columns...)
+			continue
+		}
+		r.Columns = append(r.Columns, c)
+	}
+	switch {
+	case r.Key == nil, r.Key.GoType == "string", strings.Contains(r.Key.GoType, "int"):
+	default:
+		r.Key = nil
+	}
+	if r.Key == nil || len(r.Columns) < 2 {
+		return nil
+	}
+	return r
+}
+
+// ColumnName returns the column name of a proto field or message like the default gorm naming,
+// e.g. create_time for createTime and user_profile for UserProfile.
+func ColumnName(name string) string {
+	var b strings.Builder
+	prev := '_'
+	for _, r := range GoName(name) {
+		if unicode.IsUpper(r) && prev != '_' && !unicode.IsUpper(prev) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// plural returns the plural of an english noun in snake case, e.g. users and categories.
+func plural(name string) string {
+	switch {
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsRune("aeiou", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	default:
+		return name + "s"
+	}
+}
@@ -0,0 +1,40 @@
+package base
+
+import (
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// EntityNames assigns the names of the biz entities of rpc requests and replies.
+// An entity is named after its message without the Request or Reply suffix, e.g. CreateUser
+// for CreateUserRequest, unless another message would get the same name: ListUsersReply keeps
+// its name next to ListUsers, and SearchReply keeps its name when SearchRequest already is Search,
+// so that every message maps to its own entity.
+type EntityNames struct {
+	messages map[string]bool   // names of the messages of the proto file
+	owners   map[string]string // entity name → message name
+}
+
+// NewEntityNames returns the entity names of the messages of the definition.
+func NewEntityNames(definition *proto.Proto) *EntityNames {
+	n := &EntityNames{messages: make(map[string]bool), owners: make(map[string]string)}
+	proto.Walk(definition, proto.WithMessage(func(m *proto.Message) {
+		n.messages[m.Name] = true
+	}))
+	return n
+}
+
+// RPC returns the entity names of the request and reply of the rpc.
+func (n *EntityNames) RPC(rpc *proto.RPC) (string, string) {
+	return n.name(typeName(rpc.RequestType), "Request"), n.name(typeName(rpc.ReturnsType), "Reply")
+}
+
+func (n *EntityNames) name(message, suffix string) string {
+	name := strings.TrimSuffix(message, suffix)
+	if owner, ok := n.owners[name]; (ok && owner != message) || (name != message && n.messages[name]) {
+		name = message
+	}
+	n.owners[name] = message
+	return name
+}
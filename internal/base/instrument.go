@@ -0,0 +1,100 @@
+package base
+
+import (
+	"bytes"
+	"go/format"
+	"path/filepath"
+	"text/template"
+
+	"github.com/emicklei/proto"
+)
+
+// InstrumentFile is the file of the OpenTelemetry helpers used by the instrumented layers.
+const InstrumentFile = "instrument.go"
+
+// Operation returns the name of the spans and the operation attribute of the metrics of the rpc in all layers:
+// the proto Service/Method, e.g. Greeter/SayHello. The layer attribute tells the layers apart.
+func Operation(s *proto.Service, rpc *proto.RPC) string {
+	return s.Name + "/" + rpc.Name
+}
+
+// instrumentTemplate declares the tracer and the per method metrics of a layer package.
+// The tracer and meter are named kratos like the ones of the kratos tracing and metrics middlewares,
+// and the spans are started from the request context: they are children of the middleware span,
+// and the spans of the service, biz and data layers are nested in the same trace.
+// The spans of an rpc have the same name in all layers and a layer attribute.
+var instrumentTemplate = `package {{ .Package }}
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// layer is the layer attribute of the spans and metrics of the package.
+const layer = "{{ .Layer }}"
+
+var (
+	tracer = otel.Tracer("kratos")
+
+	// methodSeconds is the latency of the {{ .Layer }} methods.
+	methodSeconds metric.Float64Histogram
+	// methodErrors counts the errors returned by the {{ .Layer }} methods.
+	methodErrors metric.Int64Counter
+)
+
+func init() {
+	meter := otel.Meter("kratos")
+	var err error
+	methodSeconds, err = meter.Float64Histogram("{{ .Layer }}_method_duration_seconds",
+		metric.WithDescription("Latency of the {{ .Layer }} methods."), metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	methodErrors, err = meter.Int64Counter("{{ .Layer }}_method_errors_total",
+		metric.WithDescription("Errors returned by the {{ .Layer }} methods."))
+	if err != nil {
+		otel.Handle(err)
+	}
+}
+
+// observe ends the span of the operation, recording the error if any, and records its metrics.
+// It is deferred at the start of the methods: defer observe(ctx, span, "Service/Method", time.Now(), &err).
+func observe(ctx context.Context, span trace.Span, operation string, start time.Time, err *error) {
+	span.SetAttributes(attribute.String("layer", layer))
+	attrs := metric.WithAttributes(attribute.String("operation", operation), attribute.String("layer", layer))
+	if methodSeconds != nil {
+		methodSeconds.Record(ctx, time.Since(start).Seconds(), attrs)
+	}
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+		if methodErrors != nil {
+			methodErrors.Add(ctx, 1, attrs)
+		}
+	}
+	span.End()
+}
+`
+
+// WriteInstrument writes the OpenTelemetry helpers of the layer package into dir, unless they already exist.
+func WriteInstrument(dir, pkg, layer string) (*Change, error) {
+	tmpl, err := template.New("instrument").Parse(instrumentTemplate)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, map[string]string{"Package": pkg, "Layer": layer}); err != nil {
+		return nil, err
+	}
+	b, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return WriteGo(filepath.Join(dir, InstrumentFile), b, false)
+}
@@ -0,0 +1,114 @@
+package base
+
+import (
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// fieldMaskType is the proto type of the update masks.
+const fieldMaskType = "google.protobuf.FieldMask"
+
+// Mask is the field mask of an Update RPC, selecting the fields of the resource to update.
+type Mask struct {
+	Name     string   // proto name of the google.protobuf.FieldMask field of the request, e.g. update_mask
+	Field    string   // go name of the mask field, e.g. UpdateMask
+	Resource string   // go name of the request field holding the resource, e.g. User
+	Type     string   // message type of the resource, e.g. User
+	Fields   []string // proto names of the fields of the resource
+	Paths    []string // valid mask paths: the fields, and the fields of the singular message fields
+}
+
+// UpdateMask returns the field mask of the rpc if it is an Update method whose request has
+// a google.protobuf.FieldMask field and a resource message field, or nil.
+// The resource is the request field of the reply type, falling back to the first message field.
+func UpdateMask(definition *proto.Proto, rpc *proto.RPC) *Mask {
+	if !strings.HasPrefix(rpc.Name, "Update") {
+		return nil
+	}
+	messages := make(map[string]*proto.Message)
+	proto.Walk(definition, proto.WithMessage(func(m *proto.Message) {
+		messages[m.Name] = m
+	}))
+	request := messages[typeName(rpc.RequestType)]
+	if request == nil {
+		return nil
+	}
+	var (
+		mask      = new(Mask)
+		resources []*proto.NormalField
+	)
+	for _, e := range request.Elements {
+		f, ok := e.(*proto.NormalField)
+		if !ok || f.Repeated {
+			continue
+		}
+		switch {
+		case strings.TrimPrefix(f.Type, ".") == fieldMaskType:
+			if mask.Name == "" {
+				mask.Name, mask.Field = f.Name, GoName(f.Name)
+			}
+		case messages[typeName(f.Type)] != nil:
+			resources = append(resources, f)
+		}
+	}
+	if mask.Name == "" || len(resources) == 0 {
+		return nil
+	}
+	r := resources[0]
+	for _, f := range resources {
+		if typeName(f.Type) == typeName(rpc.ReturnsType) {
+			r = f
+			break
+		}
+	}
+	mask.Resource, mask.Type = GoName(r.Name), typeName(r.Type)
+	resource := messages[mask.Type]
+	for _, e := range resource.Elements {
+		switch f := e.(type) {
+		case *proto.NormalField:
+			mask.Fields = append(mask.Fields, f.Name)
+		case *proto.MapField:
+			mask.Fields = append(mask.Fields, f.Name)
+		case *proto.Oneof:
+			for _, oe := range f.Elements {
+				if of, ok := oe.(*proto.OneOfField); ok {
+					mask.Fields = append(mask.Fields, of.Name)
+				}
+			}
+		}
+	}
+	mask.Paths = maskPaths(messages, resource, "", map[*proto.Message]bool{})
+	return mask
+}
+
+// maskPaths returns the paths of the fields of the message, recursing into the singular message fields.
+func maskPaths(messages map[string]*proto.Message, m *proto.Message, prefix string, seen map[*proto.Message]bool) []string {
+	seen[m] = true
+	defer delete(seen, m)
+	var paths []string
+	for _, e := range m.Elements {
+		var f *proto.Field
+		nested := false
+		switch e := e.(type) {
+		case *proto.NormalField:
+			f, nested = e.Field, !e.Repeated
+		case *proto.MapField:
+			f = e.Field
+		case *proto.Oneof:
+			for _, oe := range e.Elements {
+				if of, ok := oe.(*proto.OneOfField); ok {
+					paths = append(paths, prefix+of.Name)
+				}
+			}
+			continue
+		default:
+			continue
+		}
+		paths = append(paths, prefix+f.Name)
+		if sub := messages[typeName(f.Type)]; nested && sub != nil && !seen[sub] {
+			paths = append(paths, maskPaths(messages, sub, prefix+f.Name+".", seen)...)
+		}
+	}
+	return paths
+}
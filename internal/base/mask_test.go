@@ -0,0 +1,98 @@
+package base
+
+import (
+	"slices"
+	"testing"
+)
+
+const maskProto = `syntax = "proto3";
+package user.v1;
+
+import "google/protobuf/field_mask.proto";
+
+service Users {
+  rpc UpdateUser (UpdateUserRequest) returns (User);
+  rpc UpdateProfile (UpdateProfileRequest) returns (UpdateProfileReply);
+  rpc UpdateNode (UpdateNodeRequest) returns (Node);
+  rpc UpdateName (UpdateNameRequest) returns (User);
+  rpc PatchUser (UpdateUserRequest) returns (User);
+}
+
+message Address { string city = 1; string zip = 2; }
+message User {
+  string name = 1;
+  Address address = 2;
+  repeated Address previous = 3;
+  map<string, string> labels = 4;
+  oneof contact {
+    string email = 5;
+    string phone = 6;
+  }
+}
+message UpdateUserRequest { User user = 1; google.protobuf.FieldMask update_mask = 2; }
+message UpdateProfileRequest { Address origin = 1; User profile = 2; .google.protobuf.FieldMask mask = 3; }
+message UpdateProfileReply {}
+message Node { string id = 1; Node parent = 2; }
+message UpdateNodeRequest { google.protobuf.FieldMask update_mask = 1; Node node = 2; }
+message UpdateNameRequest { string name = 1; google.protobuf.FieldMask update_mask = 2; }
+`
+
+func TestUpdateMask(t *testing.T) {
+	userPaths := []string{"name", "address", "address.city", "address.zip", "previous", "labels", "email", "phone"}
+	tests := []struct {
+		rpc  string
+		want *Mask
+	}{
+		{
+			rpc: "UpdateUser",
+			want: &Mask{
+				Name: "update_mask", Field: "UpdateMask", Resource: "User", Type: "User",
+				Fields: []string{"name", "address", "previous", "labels", "email", "phone"},
+				Paths:  userPaths,
+			},
+		},
+		{
+			// the reply is not the resource: the first message field that is not a mask
+			rpc: "UpdateProfile",
+			want: &Mask{
+				Name: "mask", Field: "Mask", Resource: "Origin", Type: "Address",
+				Fields: []string{"city", "zip"},
+				Paths:  []string{"city", "zip"},
+			},
+		},
+		{
+			// recursive messages are not expanded twice
+			rpc: "UpdateNode",
+			want: &Mask{
+				Name: "update_mask", Field: "UpdateMask", Resource: "Node", Type: "Node",
+				Fields: []string{"id", "parent"},
+				Paths:  []string{"id", "parent"},
+			},
+		},
+		{rpc: "UpdateName"},
+		{rpc: "PatchUser"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rpc, func(t *testing.T) {
+			got := UpdateMask(parseRPC(t, maskProto, tt.rpc))
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("UpdateMask() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("UpdateMask() = nil, want %+v", tt.want)
+			}
+			if got.Name != tt.want.Name || got.Field != tt.want.Field || got.Resource != tt.want.Resource || got.Type != tt.want.Type {
+				t.Errorf("UpdateMask() = %+v, want %+v", got, tt.want)
+			}
+			if !slices.Equal(got.Fields, tt.want.Fields) {
+				t.Errorf("UpdateMask().Fields = %v, want %v", got.Fields, tt.want.Fields)
+			}
+			if !slices.Equal(got.Paths, tt.want.Paths) {
+				t.Errorf("UpdateMask().Paths = %v, want %v", got.Paths, tt.want.Paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Package base contains the helpers shared by the layer generators.
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MergeGo adds to the existing go file the declarations of the generated one it lacks:
+// functions and methods, types, variables and constants, and methods of interfaces declared in both files.
+// Existing declarations are never modified, so hand written code is kept.
+// It returns the merged file and the names of the added declarations.
+func MergeGo(existing, generated []byte) ([]byte, []string, error) {
+	fset := token.NewFileSet()
+	old, err := parser.ParseFile(fset, "existing.go", existing, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	gen, err := parser.ParseFile(fset, "generated.go", generated, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		funcs      = make(map[string]bool)
+		values     = make(map[string]bool)
+		types      = make(map[string]bool)
+		interfaces = make(map[string]*ast.InterfaceType)
+	)
+	for _, d := range old.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			funcs[funcKey(d)] = true
+		case *ast.GenDecl:
+			for _, s := range d.Specs {
+				if vs, ok := s.(*ast.ValueSpec); ok {
+					for _, n := range vs.Names {
+						values[n.Name] = true
+					}
+				}
+				if ts, ok := s.(*ast.TypeSpec); ok {
+					types[ts.Name.Name] = true
+					if it, ok := ts.Type.(*ast.InterfaceType); ok {
+						interfaces[ts.Name.Name] = it
+					}
+				}
+			}
+		}
+	}
+
+	var (
+		edits []edit
+		tail  []string
+		added []string
+	)
+	for _, d := range gen.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if key := funcKey(d); !funcs[key] {
+				tail = append(tail, text(fset, generated, d, d.Doc))
+				added = append(added, key)
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.VAR || d.Tok == token.CONST {
+				for _, s := range d.Specs {
+					vs := s.(*ast.ValueSpec)
+					if len(vs.Names) == 0 || values[vs.Names[0].Name] {
+						continue
+					}
+					tail = append(tail, d.Tok.String()+" "+text(fset, generated, vs, nil))
+					added = append(added, vs.Names[0].Name)
+				}
+				continue
+			}
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, s := range d.Specs {
+				ts := s.(*ast.TypeSpec)
+				if !types[ts.Name.Name] {
+					doc := ts.Doc
+					if doc == nil && len(d.Specs) == 1 {
+						doc = d.Doc
+					}
+					decl := "type " + text(fset, generated, ts, nil)
+					if doc != nil {
+						decl = text(fset, generated, doc, nil) + "\n" + decl
+					}
+					tail = append(tail, decl)
+					added = append(added, ts.Name.Name)
+					continue
+				}
+				genIface, ok := ts.Type.(*ast.InterfaceType)
+				oldIface := interfaces[ts.Name.Name]
+				if !ok || oldIface == nil {
+					continue
+				}
+				methods := make(map[string]bool)
+				for _, m := range oldIface.Methods.List {
+					for _, n := range m.Names {
+						methods[n.Name] = true
+					}
+				}
+				for _, m := range genIface.Methods.List {
+					if len(m.Names) == 0 || methods[m.Names[0].Name] {
+						continue
+					}
+					edits = append(edits, edit{
+						offset: fset.Position(oldIface.Methods.Closing).Offset,
+						text:   "\t" + text(fset, generated, m, m.Doc) + "\n",
+					})
+					added = append(added, ts.Name.Name+"."+m.Names[0].Name)
+				}
+			}
+		}
+	}
+	if len(added) == 0 {
+		return existing, nil, nil
+	}
+	edits = append(edits, importEdits(fset, old, gen, existing, generated, strings.Join(tail, "\n")+editsText(edits))...)
+
+	out := applyEdits(existing, edits)
+	for _, t := range tail {
+		out = append(out, "\n\n"...)
+		out = append(out, t...)
+	}
+	out = append(out, '\n')
+	formatted, err := format.Source(out)
+	if err != nil {
+		return nil, nil, fmt.Errorf("merge: %v", err)
+	}
+	return formatted, added, nil
+}
+
+type edit struct {
+	offset int
+	text   string
+}
+
+func applyEdits(src []byte, edits []edit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].offset > edits[j].offset })
+	out := append([]byte(nil), src...)
+	for _, e := range edits {
+		out = append(out[:e.offset], append([]byte(e.text), out[e.offset:]...)...)
+	}
+	return out
+}
+
+func editsText(edits []edit) string {
+	var b strings.Builder
+	for _, e := range edits {
+		b.WriteString(e.text)
+	}
+	return b.String()
+}
+
+// importEdits adds the imports of the generated file used by the added code and missing from the existing file.
+func importEdits(fset *token.FileSet, old, gen *ast.File, existing, generated []byte, added string) []edit {
+	have := make(map[string]bool)
+	for _, i := range old.Imports {
+		have[i.Path.Value] = true
+	}
+	var specs []string
+	for _, i := range gen.Imports {
+		if have[i.Path.Value] || !strings.Contains(added, importName(i)+".") {
+			continue
+		}
+		specs = append(specs, text(fset, generated, i, nil))
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	for _, d := range old.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			if gd.Rparen.IsValid() {
+				return []edit{{offset: fset.Position(gd.Rparen).Offset, text: "\t" + strings.Join(specs, "\n\t") + "\n"}}
+			}
+			return []edit{{offset: fset.Position(gd.End()).Offset, text: "\nimport (\n\t" + strings.Join(specs, "\n\t") + "\n)"}}
+		}
+	}
+	return []edit{{offset: fset.Position(old.Name.End()).Offset, text: "\n\nimport (\n\t" + strings.Join(specs, "\n\t") + "\n)"}}
+}
+
+// importName returns the name an import is referred to, guessing it from the path when not explicit.
+func importName(i *ast.ImportSpec) string {
+	if i.Name != nil {
+		return i.Name.Name
+	}
+	p, _ := strconv.Unquote(i.Path.Value)
+	name := path.Base(p)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		// major version suffix, e.g. github.com/go-kratos/kratos/v2
+		name = path.Base(path.Dir(p))
+	}
+	return name
+}
+
+// funcKey returns the receiver qualified name of a function, e.g. UserService.GetUser.
+func funcKey(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return d.Name.Name
+	}
+	t := d.Recv.List[0].Type
+	if s, ok := t.(*ast.StarExpr); ok {
+		t = s.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name + "." + d.Name.Name
+	}
+	return d.Name.Name
+}
+
+// text returns the source of the node, including its doc comment.
+func text(fset *token.FileSet, src []byte, n ast.Node, doc *ast.CommentGroup) string {
+	start := n.Pos()
+	if doc != nil {
+		start = doc.Pos()
+	}
+	return string(bytes.TrimSpace(src[fset.Position(start).Offset:fset.Position(n.End()).Offset]))
+}
@@ -0,0 +1,230 @@
+package base
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"testing"
+)
+
+const mergeGenerated = `package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// ErrNotFound is returned for missing users.
+var ErrNotFound = errors.New("not found")
+
+const defaultPageSize = 20
+
+// UserRepo stores the users.
+type UserRepo interface {
+	Get(ctx context.Context, id int64) (*User, error)
+	// Delete removes the user.
+	Delete(ctx context.Context, id int64) error
+}
+
+type User struct {
+	ID int64
+}
+
+// UserService serves the users.
+type UserService struct {
+	repo UserRepo
+	log  *log.Helper
+}
+
+func (s *UserService) GetUser(ctx context.Context, id int64) (*User, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// DeleteUser deletes the user.
+func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
+	return s.repo.Delete(ctx, id)
+}
+`
+
+func TestMergeGo(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+		added    []string
+	}{
+		{
+			name:     "up to date",
+			existing: mergeGenerated,
+			want:     mergeGenerated,
+		},
+		{
+			name: "missing declarations",
+			existing: `package service
+
+import "context"
+
+// UserRepo stores the users.
+type UserRepo interface {
+	Get(ctx context.Context, id int64) (*User, error)
+	// Count is hand written.
+	Count(ctx context.Context) (int, error)
+}
+
+type User struct {
+	ID   int64
+	Name string
+}
+
+// GetUser is hand written.
+func (s *UserService) GetUser(ctx context.Context, id int64) (*User, error) {
+	return &User{ID: id}, nil
+}
+`,
+			want: `package service
+
+import "context"
+import (
+	"errors"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// UserRepo stores the users.
+type UserRepo interface {
+	Get(ctx context.Context, id int64) (*User, error)
+	// Count is hand written.
+	Count(ctx context.Context) (int, error)
+	// Delete removes the user.
+	Delete(ctx context.Context, id int64) error
+}
+
+type User struct {
+	ID   int64
+	Name string
+}
+
+// GetUser is hand written.
+func (s *UserService) GetUser(ctx context.Context, id int64) (*User, error) {
+	return &User{ID: id}, nil
+}
+
+var ErrNotFound = errors.New("not found")
+
+const defaultPageSize = 20
+
+// UserService serves the users.
+type UserService struct {
+	repo UserRepo
+	log  *log.Helper
+}
+
+// DeleteUser deletes the user.
+func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
+	return s.repo.Delete(ctx, id)
+}
+`,
+			added: []string{"ErrNotFound", "defaultPageSize", "UserRepo.Delete", "UserService", "UserService.DeleteUser"},
+		},
+		{
+			name: "no imports",
+			existing: `package service
+
+type User struct {
+	ID int64
+}
+
+type UserRepo interface {
+	Get(id int64) (*User, error)
+	Delete(id int64) error
+}
+
+var ErrNotFound error
+
+const defaultPageSize = 10
+
+type UserService struct{}
+
+func (s *UserService) GetUser() {}
+`,
+			want: `package service
+
+import (
+	"context"
+)
+
+type User struct {
+	ID int64
+}
+
+type UserRepo interface {
+	Get(id int64) (*User, error)
+	Delete(id int64) error
+}
+
+var ErrNotFound error
+
+const defaultPageSize = 10
+
+type UserService struct{}
+
+func (s *UserService) GetUser() {}
+
+// DeleteUser deletes the user.
+func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
+	return s.repo.Delete(ctx, id)
+}
+`,
+			added: []string{"UserService.DeleteUser"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, added, err := MergeGo([]byte(tt.existing), []byte(mergeGenerated))
+			if err != nil {
+				t.Fatalf("MergeGo() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("MergeGo() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if !slices.Equal(added, tt.added) {
+				t.Errorf("MergeGo() added = %v, want %v", added, tt.added)
+			}
+		})
+	}
+}
+
+func TestMergeGoInvalid(t *testing.T) {
+	if _, _, err := MergeGo([]byte("package service\n\nfunc {"), []byte(mergeGenerated)); err == nil {
+		t.Error("MergeGo() error = nil, want the parse error of the existing file")
+	}
+}
+
+func TestImportName(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: `import "context"`, want: "context"},
+		{src: `import "github.com/go-kratos/kratos/v2/log"`, want: "log"},
+		{src: `import "github.com/go-kratos/kratos/v2"`, want: "kratos"},
+		{src: `import pb "example.com/api/user/v1"`, want: "pb"},
+	}
+	for _, tt := range tests {
+		f := parseFile(t, "package p\n\n"+tt.src+"\n")
+		if got := importName(f.Imports[0]); got != tt.want {
+			t.Errorf("importName(%s) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func parseFile(t *testing.T, src string) *ast.File {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
@@ -0,0 +1,135 @@
+package base
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Module is the go module containing the generated code.
+type Module struct {
+	Path string // module path declared in go.mod
+	Dir  string // absolute directory of go.mod
+}
+
+// FindModule returns the module containing dir.
+// Modules of a go.work workspace take precedence over the nearest go.mod in the parent directories.
+func FindModule(dir string) (*Module, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	work, err := findWorkFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if work != "" {
+		m, err := workspaceModule(work, dir)
+		if err != nil || m != nil {
+			return m, err
+		}
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if m, err := readModule(d); err == nil {
+			return m, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if filepath.Dir(d) == d {
+			return nil, fmt.Errorf("no go.mod found in %s or any parent directory, run go mod init first", dir)
+		}
+	}
+}
+
+// findWorkFile returns the go.work file of the workspace, honoring the GOWORK environment variable.
+func findWorkFile(dir string) (string, error) {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return "", nil
+	case "":
+	default:
+		return filepath.Abs(gowork)
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		name := filepath.Join(d, "go.work")
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		}
+		if filepath.Dir(d) == d {
+			return "", nil
+		}
+	}
+}
+
+// workspaceModule returns the module of the workspace whose directory is the closest parent of dir,
+// or nil if dir does not belong to any module used by the workspace.
+func workspaceModule(work, dir string) (*Module, error) {
+	data, err := os.ReadFile(work)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := modfile.ParseWork(work, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	var best string
+	for _, u := range wf.Use {
+		d := u.Path
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(filepath.Dir(work), d)
+		}
+		if within(d, dir) && len(d) > len(best) {
+			best = d
+		}
+	}
+	if best == "" {
+		return nil, nil
+	}
+	return readModule(best)
+}
+
+func readModule(dir string) (*Module, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	path := modfile.ModulePath(data)
+	if path == "" {
+		return nil, fmt.Errorf("%s: missing module declaration", filepath.Join(dir, "go.mod"))
+	}
+	return &Module{Path: path, Dir: dir}, nil
+}
+
+// within reports whether dir is parent or equal to target.
+func within(dir, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ImportPath returns the go import path of dir inside the module.
+func (m *Module) ImportPath(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(m.Dir, dir)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return m.Path, nil
+	}
+	return m.Path + "/" + filepath.ToSlash(rel), nil
+}
+
+// ImportPath returns the go import path of dir inside the module containing it.
+func ImportPath(dir string) (string, error) {
+	m, err := FindModule(dir)
+	if err != nil {
+		return "", err
+	}
+	return m.ImportPath(dir)
+}
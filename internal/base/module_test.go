@@ -0,0 +1,132 @@
+package base
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindModule(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		gowork string
+		dir    string
+		module string // module directory relative to the root, empty when no module is found
+		path   string // import path of dir
+		err    string
+	}{
+		{
+			name:   "single module",
+			files:  map[string]string{"go.mod": "module example.com/shop\n"},
+			dir:    ".",
+			module: ".",
+			path:   "example.com/shop",
+		},
+		{
+			name:   "parent module",
+			files:  map[string]string{"go.mod": "module example.com/shop\n", "internal/biz/biz.go": "package biz\n"},
+			dir:    "internal/biz",
+			module: ".",
+			path:   "example.com/shop/internal/biz",
+		},
+		{
+			name: "workspace with several modules",
+			files: map[string]string{
+				"go.work":         "go 1.24\n\nuse (\n\t./order\n\t./user\n)\n",
+				"order/go.mod":    "module example.com/order\n",
+				"user/go.mod":     "module example.com/user\n",
+				"user/api/v1/.ok": "",
+			},
+			dir:    "user/api/v1",
+			module: "user",
+			path:   "example.com/user/api/v1",
+		},
+		{
+			name: "nested workspace modules",
+			files: map[string]string{
+				"go.work":                      "go 1.24\n\nuse (\n\t.\n\t./tools/gen\n)\n",
+				"go.mod":                       "module example.com/shop\n",
+				"tools/gen/go.mod":             "module example.com/shop/tools/gen\n",
+				"tools/gen/internal/biz/.keep": "",
+			},
+			dir:    "tools/gen/internal/biz",
+			module: "tools/gen",
+			path:   "example.com/shop/tools/gen/internal/biz",
+		},
+		{
+			name: "directory outside the workspace modules",
+			files: map[string]string{
+				"go.work":       "go 1.24\n\nuse ./order\n",
+				"order/go.mod":  "module example.com/order\n",
+				"legacy/go.mod": "module example.com/legacy\n",
+				"legacy/v1/.ok": "",
+			},
+			dir:    "legacy/v1",
+			module: "legacy",
+			path:   "example.com/legacy/v1",
+		},
+		{
+			name: "workspace disabled",
+			files: map[string]string{
+				"go.work":         "go 1.24\n\nuse ./user\n",
+				"go.mod":          "module example.com/shop\n",
+				"user/go.mod":     "module example.com/user\n",
+				"user/api/v1/.ok": "",
+			},
+			gowork: "off",
+			dir:    "user/api/v1",
+			module: "user",
+			path:   "example.com/user/api/v1",
+		},
+		{
+			name:  "missing module declaration",
+			files: map[string]string{"go.mod": "go 1.24\n"},
+			dir:   ".",
+			err:   "missing module declaration",
+		},
+		{
+			name:  "no module",
+			files: map[string]string{"api/v1/.ok": ""},
+			dir:   "api/v1",
+			err:   "no go.mod found in ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOWORK", tt.gowork)
+			root := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(root, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dir := filepath.Join(root, filepath.FromSlash(tt.dir))
+			m, err := FindModule(dir)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("FindModule() error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindModule() error = %v", err)
+			}
+			if want := filepath.Join(root, filepath.FromSlash(tt.module)); m.Dir != want {
+				t.Errorf("FindModule().Dir = %s, want %s", m.Dir, want)
+			}
+			path, err := m.ImportPath(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != tt.path {
+				t.Errorf("ImportPath() = %s, want %s", path, tt.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package base
+
+import (
+	"bytes"
+	"go/format"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/emicklei/proto"
+)
+
+// PaginationFile is the file of the pagination helpers of the List methods.
+const PaginationFile = "pagination.go"
+
+// Page is the pagination of a List RPC, detected from the fields of its request and reply messages.
+// The fields are the go names of the proto fields, e.g. PageSize for page_size.
+type Page struct {
+	Size      string // page_size field of the request
+	Token     string // page_token field of the request, empty for page number pagination
+	Number    string // page field of the request, empty for page token pagination
+	NextToken string // next_page_token field of the reply, if any
+	Items     string // repeated field of the reply holding the items of the page, if any
+	ItemType  string // message type of the items, empty for scalar items
+}
+
+// ListPage returns the pagination of the rpc if it is a List method whose request has a page_size field
+// and a page_token or page field, or nil.
+func ListPage(definition *proto.Proto, rpc *proto.RPC) *Page {
+	if !strings.HasPrefix(rpc.Name, "List") {
+		return nil
+	}
+	messages := make(map[string]*proto.Message)
+	proto.Walk(definition, proto.WithMessage(func(m *proto.Message) {
+		messages[m.Name] = m
+	}))
+	request := fields(messages[typeName(rpc.RequestType)])
+	if request["page_size"] == nil || (request["page_token"] == nil && request["page"] == nil) {
+		return nil
+	}
+	p := &Page{Size: GoName("page_size")}
+	if request["page_token"] != nil {
+		p.Token = GoName("page_token")
+	} else {
+		p.Number = GoName("page")
+	}
+	reply := messages[typeName(rpc.ReturnsType)]
+	if reply == nil {
+		return p
+	}
+	if fields(reply)["next_page_token"] != nil {
+		p.NextToken = GoName("next_page_token")
+	}
+	for _, e := range reply.Elements {
+		if f, ok := e.(*proto.NormalField); ok && f.Repeated {
+			p.Items = GoName(f.Name)
+			if messages[typeName(f.Type)] != nil {
+				p.ItemType = typeName(f.Type)
+			}
+			break
+		}
+	}
+	return p
+}
+
+// fields returns the normal fields of the message by name.
+func fields(m *proto.Message) map[string]*proto.NormalField {
+	res := make(map[string]*proto.NormalField)
+	if m == nil {
+		return res
+	}
+	for _, e := range m.Elements {
+		if f, ok := e.(*proto.NormalField); ok {
+			res[f.Name] = f
+		}
+	}
+	return res
+}
+
+// typeName returns the message name of a possibly qualified type, e.g. User for .user.v1.User.
+func typeName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// GoName returns the go name of a proto field like protoc-gen-go, e.g. NextPageToken for next_page_token.
+func GoName(field string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range field {
+		switch {
+		case r == '_':
+			upper = true
+		case upper && 'a' <= r && r <= 'z':
+			b.WriteRune(r - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(r)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// paginationTemplate declares the pagination helpers of a layer package.
+// The page tokens are opaque cursors holding the offset of the next page: only offset pagination is generated,
+// the generated List queries order the items by their id so that the offsets are stable.
+var paginationTemplate = `package {{ .Package }}
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/go-kratos/kratos/v2/errors"
+)
+
+const (
+	// defaultPageSize is the page size of the requests without page_size.
+	defaultPageSize = 20
+	// maxPageSize is the maximum page size, larger page sizes are reduced to it.
+	maxPageSize = 100
+)
+
+// ListOptions are the pagination options of a List method.
+// The pagination is offset based: the queries order the items by a unique key, such as the id,
+// and skip the Offset first ones. Items created or deleted between two pages shift the following ones;
+// switch to keyset pagination, filtering on the key of the last item of the previous page, if it matters.
+type ListOptions struct {
+	Limit  int // maximum number of items of the page
+	Offset int // number of items before the page
+}
+
+// pageCursor is the content of the page tokens.
+type pageCursor struct {
+	Offset int ` + "`json:\"offset\"`" + `
+}
+
+// newListOptions returns the options of the page_size and page_token of a request.
+func newListOptions(pageSize int, pageToken string) (*ListOptions, error) {
+	opts := &ListOptions{Limit: pageLimit(pageSize)}
+	if pageToken == "" {
+		return opts, nil
+	}
+	offset, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, err
+	}
+	opts.Offset = offset
+	return opts, nil
+}
+
+// newPageOptions returns the options of the page number, starting at 1, and page_size of a request.
+func newPageOptions(page, pageSize int) *ListOptions {
+	opts := &ListOptions{Limit: pageLimit(pageSize)}
+	if page > 1 {
+		opts.Offset = (page - 1) * opts.Limit
+	}
+	return opts
+}
+
+// NextPageToken returns the token of the page following the n items of the page,
+// or an empty token if the page is not full and thus the last one.
+func (o *ListOptions) NextPageToken(n int) string {
+	if n < o.Limit {
+		return ""
+	}
+	return encodePageToken(o.Offset + n)
+}
+
+func pageLimit(pageSize int) int {
+	switch {
+	case pageSize <= 0:
+		return defaultPageSize
+	case pageSize > maxPageSize:
+		return maxPageSize
+	default:
+		return pageSize
+	}
+}
+
+func encodePageToken(offset int) string {
+	b, _ := json.Marshal(pageCursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePageToken(token string) (int, error) {
+	var c pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || c.Offset < 0 {
+		return 0, errors.BadRequest("INVALID_PAGE_TOKEN", "invalid page token")
+	}
+	return c.Offset, nil
+}
+`
+
+// WritePagination writes the pagination helpers of the layer package into dir, unless they already exist.
+func WritePagination(dir, pkg string) (*Change, error) {
+	tmpl, err := template.New("pagination").Parse(paginationTemplate)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, map[string]string{"Package": pkg}); err != nil {
+		return nil, err
+	}
+	b, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return WriteGo(filepath.Join(dir, PaginationFile), b, false)
+}
@@ -0,0 +1,87 @@
+package base
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emicklei/proto"
+)
+
+// parseRPC parses the proto source and returns its rpc of the given name.
+func parseRPC(t *testing.T, src, name string) (*proto.Proto, *proto.RPC) {
+	t.Helper()
+	definition, err := proto.NewParser(strings.NewReader(src)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rpc *proto.RPC
+	proto.Walk(definition, proto.WithRPC(func(r *proto.RPC) {
+		if r.Name == name {
+			rpc = r
+		}
+	}))
+	if rpc == nil {
+		t.Fatalf("rpc %s not found", name)
+	}
+	return definition, rpc
+}
+
+const pageProto = `syntax = "proto3";
+package book.v1;
+
+service Library {
+  rpc ListBooks (ListBooksRequest) returns (ListBooksReply);
+  rpc ListShelves (ListShelvesRequest) returns (ListShelvesReply);
+  rpc ListTags (ListTagsRequest) returns (ListTagsReply);
+  rpc ListAuthors (ListAuthorsRequest) returns (ListAuthorsReply);
+  rpc SearchBooks (ListBooksRequest) returns (ListBooksReply);
+}
+
+message Book { string name = 1; }
+message ListBooksRequest { int32 page_size = 1; string page_token = 2; }
+message ListBooksReply { int32 total = 1; repeated Book books = 2; string next_page_token = 3; }
+message ListShelvesRequest { int32 page = 1; int32 page_size = 2; }
+message ListShelvesReply { repeated .book.v1.Book shelves = 1; }
+message ListTagsRequest { int32 page_size = 1; string page_token = 2; }
+message ListTagsReply { repeated string tags = 1; }
+message ListAuthorsRequest { string filter = 1; }
+message ListAuthorsReply { repeated string authors = 1; }
+`
+
+func TestListPage(t *testing.T) {
+	tests := []struct {
+		rpc  string
+		want *Page
+	}{
+		{rpc: "ListBooks", want: &Page{Size: "PageSize", Token: "PageToken", NextToken: "NextPageToken", Items: "Books", ItemType: "Book"}},
+		{rpc: "ListShelves", want: &Page{Size: "PageSize", Number: "Page", Items: "Shelves", ItemType: "Book"}},
+		{rpc: "ListTags", want: &Page{Size: "PageSize", Token: "PageToken", Items: "Tags"}},
+		{rpc: "ListAuthors"},
+		{rpc: "SearchBooks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rpc, func(t *testing.T) {
+			got := ListPage(parseRPC(t, pageProto, tt.rpc))
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("ListPage() = %+v, want nil", got)
+			case tt.want != nil && (got == nil || *got != *tt.want):
+				t.Errorf("ListPage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"name":            "Name",
+		"next_page_token": "NextPageToken",
+		"user_id2":        "UserId2",
+		"createdAt":       "CreatedAt",
+	}
+	for in, want := range tests {
+		if got := GoName(in); got != want {
+			t.Errorf("GoName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
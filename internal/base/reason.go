@@ -0,0 +1,152 @@
+package base
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// Reasons are the error reasons of a proto file, as generated by protoc-gen-go-errors.
+type Reasons struct {
+	GoPackage string // import path of the generated go package
+	GoName    string // name of the generated go package
+	Reasons   []*Reason
+}
+
+// Reason is a value of an error reason enum.
+type Reason struct {
+	Enum    string // go name of the enum, e.g. ErrorReason
+	Name    string // name of the value, e.g. USER_NOT_FOUND
+	Code    int    // HTTP code of the errors.code option, or errors.default_code of the enum
+	Comment string
+}
+
+// ParseReasons returns the error reasons of the enums of the proto file having
+// an errors.default_code option or values with an errors.code option.
+// Like protoc-gen-go-errors, the values without any code are skipped.
+func ParseReasons(protoFile string) (*Reasons, error) {
+	reader, err := os.Open(protoFile)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	definition, err := proto.NewParser(reader).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", protoFile, err)
+	}
+	rs := new(Reasons)
+	proto.Walk(definition,
+		proto.WithOption(func(o *proto.Option) {
+			if o.Name == "go_package" {
+				rs.GoPackage, rs.GoName, _ = strings.Cut(o.Constant.Source, ";")
+				if rs.GoName == "" {
+					rs.GoName = path.Base(rs.GoPackage)
+				}
+			}
+		}),
+		proto.WithEnum(func(e *proto.Enum) {
+			defaultCode := 0
+			for _, el := range e.Elements {
+				if o, ok := el.(*proto.Option); ok && o.Name == "(errors.default_code)" {
+					defaultCode, _ = strconv.Atoi(o.Constant.Source)
+				}
+			}
+			for _, el := range e.Elements {
+				f, ok := el.(*proto.EnumField)
+				if !ok {
+					continue
+				}
+				code := defaultCode
+				for _, fe := range f.Elements {
+					if o, ok := fe.(*proto.Option); ok && o.Name == "(errors.code)" {
+						code, _ = strconv.Atoi(o.Constant.Source)
+					}
+				}
+				if code == 0 {
+					continue
+				}
+				r := &Reason{Enum: enumGoName(e), Name: f.Name, Code: code}
+				if f.Comment != nil {
+					r.Comment = strings.TrimSpace(f.Comment.Message())
+				}
+				rs.Reasons = append(rs.Reasons, r)
+			}
+		}),
+	)
+	if rs.GoPackage == "" {
+		return nil, fmt.Errorf("%s: missing go_package option", protoFile)
+	}
+	if len(rs.Reasons) == 0 {
+		return nil, fmt.Errorf("%s: no error reason with an errors.code or errors.default_code option", protoFile)
+	}
+	return rs, nil
+}
+
+// enumGoName returns the go name of the enum, prefixed by its parent messages like protoc-gen-go.
+func enumGoName(e *proto.Enum) string {
+	name := e.Name
+	for p := e.Parent; p != nil; {
+		m, ok := p.(*proto.Message)
+		if !ok {
+			break
+		}
+		name = m.Name + "_" + name
+		p = m.Parent
+	}
+	return name
+}
+
+// NotFound returns the not found reason of the resource, e.g. USER_NOT_FOUND for User,
+// falling back to the first reason with a 404 code. It returns nil if there is none.
+func (rs *Reasons) NotFound(resource string) *Reason {
+	var first *Reason
+	for _, r := range rs.Reasons {
+		if r.Code != 404 {
+			continue
+		}
+		if strings.Contains(r.Name, strings.ToUpper(resource)) {
+			return r
+		}
+		if first == nil {
+			first = r
+		}
+	}
+	return first
+}
+
+// Var returns the name of the sentinel error of the reason, e.g. ErrUserNotFound.
+func (r *Reason) Var() string {
+	var b strings.Builder
+	b.WriteString("Err")
+	for _, w := range strings.Split(strings.ToLower(r.Name), "_") {
+		if w != "" {
+			b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+		}
+	}
+	return b.String()
+}
+
+// Message returns the default message of the reason, e.g. user not found.
+func (r *Reason) Message() string {
+	return strings.ToLower(strings.ReplaceAll(r.Name, "_", " "))
+}
+
+// Func returns the kratos errors function creating an error of the reason code, e.g. NotFound,
+// or an empty string if there is none and errors.New must be used.
+func (r *Reason) Func() string {
+	return map[int]string{
+		400: "BadRequest",
+		401: "Unauthorized",
+		403: "Forbidden",
+		404: "NotFound",
+		409: "Conflict",
+		499: "ClientClosed",
+		500: "InternalServer",
+		503: "ServiceUnavailable",
+		504: "GatewayTimeout",
+	}[r.Code]
+}
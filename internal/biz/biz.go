@@ -1,14 +1,19 @@
 package biz
 
 import (
+	"bytes"
 	"fmt"
+	"go/format"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"text/template"
 
+	"github.com/enneket/kratos-cli-boost/internal/base"
+
 	"github.com/emicklei/proto"
 	"github.com/spf13/cobra"
 	"golang.org/x/text/cases"
@@ -24,12 +29,20 @@ var CmdBiz = &cobra.Command{
 }
 
 var (
-	targetDir string // 生成目标目录
+	targetDir  string // 生成目标目录
+	withTests  bool   // 是否同时生成 UseCase 单元测试
+	errorsFile string // 错误原因 proto 文件
+	instrument bool   // 是否生成 OpenTelemetry 埋点
+	withTx     bool   // 是否生成事务接口并注入 UseCase
 )
 
 // 初始化命令行参数
 func init() {
 	CmdBiz.Flags().StringVarP(&targetDir, "target-dir", "t", "internal/biz", "generate target directory")
+	CmdBiz.Flags().BoolVar(&withTests, "with-tests", false, "also generate the UseCase unit tests with a fake Repo")
+	CmdBiz.Flags().BoolVar(&instrument, "instrument", false, "start an OpenTelemetry span and record latency and error metrics in each UseCase method")
+	CmdBiz.Flags().BoolVar(&withTx, "tx", false, "also generate the Transaction interface implemented by the data layer and inject it into the UseCase")
+	CmdBiz.Flags().StringVar(&errorsFile, "errors", "", "error reasons proto file to generate the sentinel errors from, e.g. api/xxx/v1/error_reason.proto")
 }
 
 // 核心执行逻辑
@@ -40,10 +53,157 @@ func run(_ *cobra.Command, args []string) {
 		return
 	}
 
+	// 检查目标目录是否存在
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		fmt.Printf("Target directory: %s does not exist, creating...\n", targetDir)
+		if err = os.MkdirAll(targetDir, 0o755); err != nil {
+			log.Fatalf("failed to create target directory: %v", err)
+		}
+	}
+
+	c, err := Generate(args[0], targetDir, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if c.Exists {
+		fmt.Fprintf(os.Stderr, "biz file already exists: %s\n", c.File)
+	} else {
+		fmt.Printf("generated biz file: %s\n", c.File)
+	}
+
+	if c, err = GeneratePagination(args[0], targetDir); err != nil {
+		log.Fatal(err)
+	}
+	if c != nil && !c.Exists {
+		fmt.Printf("generated biz pagination file: %s\n", c.File)
+	}
+
+	if instrument {
+		if c, err = base.WriteInstrument(targetDir, "biz", "biz"); err != nil {
+			log.Fatal(err)
+		}
+		if !c.Exists {
+			fmt.Printf("generated biz instrument file: %s\n", c.File)
+		}
+	}
+
+	if withTx {
+		if c, err = GenerateTransaction(targetDir); err != nil {
+			log.Fatal(err)
+		}
+		if c.Exists {
+			fmt.Fprintf(os.Stderr, "biz transaction file already exists: %s\n", c.File)
+		} else {
+			fmt.Printf("generated biz transaction file: %s\n", c.File)
+		}
+	}
+
+	if errorsFile != "" {
+		if c, err = GenerateErrors(errorsFile, targetDir); err != nil {
+			log.Fatal(err)
+		}
+		if c.Exists {
+			fmt.Fprintf(os.Stderr, "biz errors file already exists: %s\n", c.File)
+		} else {
+			fmt.Printf("generated biz errors file: %s\n", c.File)
+		}
+	}
+
+	if !withTests {
+		return
+	}
+	if c, err = GenerateTests(args[0], targetDir); err != nil {
+		log.Fatal(err)
+	}
+	if c.Exists {
+		fmt.Fprintf(os.Stderr, "biz test file already exists: %s\n", c.File)
+		return
+	}
+	fmt.Printf("generated biz test file: %s\n", c.File)
+}
+
+// Generate 生成 proto 文件对应的 biz 代码到 dir 目录
+// 文件已存在时跳过；merge 为 true 时向已有文件补充缺失的方法
+func Generate(protoFile, dir string, merge bool) (*base.Change, error) {
+	bizData, err := parse(protoFile)
+	if err != nil {
+		return nil, err
+	}
+	// 渲染 biz 层模板
+	buf, err := render(bizTemplate, bizData)
+	if err != nil {
+		return nil, err
+	}
+	// 生成文件名：小写服务名 + .go
+	filename := strings.ToLower(bizData.ServiceName) + ".go"
+	return base.WriteGo(filepath.Join(dir, filename), buf, merge)
+}
+
+// GeneratePagination 生成分页方法使用的 ListOptions 与 page token 编解码函数
+// 服务没有分页的 List 方法时不生成，返回 nil；文件已存在时跳过
+func GeneratePagination(protoFile, dir string) (*base.Change, error) {
+	bizData, err := parse(protoFile)
+	if err != nil {
+		return nil, err
+	}
+	if !bizData.Paginated() {
+		return nil, nil
+	}
+	return base.WritePagination(dir, "biz")
+}
+
+// GenerateTests 生成 biz 代码对应的单元测试：手写的 fake Repo + 每个 UseCase 方法的表驱动测试
+// 覆盖 Repo 成功与失败两种情况；文件已存在时跳过
+func GenerateTests(protoFile, dir string) (*base.Change, error) {
+	bizData, err := parse(protoFile)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := render(bizTestTemplate, bizData)
+	if err != nil {
+		return nil, err
+	}
+	// 生成文件名：小写服务名 + _test.go
+	filename := strings.ToLower(bizData.ServiceName) + "_test.go"
+	return base.WriteGo(filepath.Join(dir, filename), buf, false)
+}
+
+// GenerateErrors 根据错误原因 proto 文件生成 biz 层的业务错误（kratos errors）
+// 文件名与 proto 文件同名，已存在时跳过
+func GenerateErrors(errorsProto, dir string) (*base.Change, error) {
+	reasons, err := base.ParseReasons(errorsProto)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := template.New("bizErrorsTemplate").Parse(bizErrorsTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse biz errors template: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := tpl.Execute(buf, reasons); err != nil {
+		return nil, fmt.Errorf("failed to render biz errors template: %v", err)
+	}
+	b, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	// 生成文件名：错误原因 proto 文件名 + .go
+	filename := strings.TrimSuffix(filepath.Base(errorsProto), ".proto") + ".go"
+	return base.WriteGo(filepath.Join(dir, filename), b, false)
+}
+
+// GenerateTransaction 生成 Transaction 事务接口，由 data 层实现（kratos proto data --tx）
+// 文件已存在时跳过
+func GenerateTransaction(dir string) (*base.Change, error) {
+	return base.WriteGo(filepath.Join(dir, "transaction.go"), []byte(bizTransactionTemplate), false)
+}
+
+// parse 解析 proto 文件，提取 biz 层模板所需的服务、方法与实体信息
+func parse(protoFile string) (*BizData, error) {
 	// 打开 proto 文件
-	reader, err := os.Open(args[0])
+	reader, err := os.Open(protoFile)
 	if err != nil {
-		log.Fatalf("failed to open proto file: %v", err)
+		return nil, fmt.Errorf("failed to open proto file: %v", err)
 	}
 	defer reader.Close()
 
@@ -51,12 +211,14 @@ func run(_ *cobra.Command, args []string) {
 	parser := proto.NewParser(reader)
 	definition, err := parser.Parse()
 	if err != nil {
-		log.Fatalf("failed to parse proto file: %v", err)
+		return nil, fmt.Errorf("failed to parse proto file: %v", err)
 	}
 
 	// 提取 proto 关键信息
 
-	bizData := new(BizData)
+	bizData := &BizData{Instrument: instrument, Tx: withTx}
+	entities := newEntityBuilder(definition)
+	names := base.NewEntityNames(definition)
 
 	proto.Walk(definition,
 		// 提取服务和方法信息
@@ -75,60 +237,68 @@ func run(_ *cobra.Command, args []string) {
 				if rpc.Comment != nil {
 					comment = rpc.Comment.Message()
 				}
+				// 请求与响应对应的领域实体，字段来自 proto message
+				param, result := names.RPC(rpc)
+				entities.add(param, rpc.RequestType)
+				entities.add(result, rpc.ReturnsType)
 				// 添加方法信息
 				bizData.Methods = append(bizData.Methods, &BizMethod{
 					ServiceName: nameToUpperCamelCase(s.Name),
 					MethodName:  nameToUpperCamelCase(rpc.Name),
-					ParamType:   "*" + cleanNameRequest(cleanTypeName(rpc.RequestType)),
-					ParamName:   cleanNameRequestAndToLowerCamelCase(cleanTypeName(rpc.RequestType)),
-					ReturnType:  "*" + cleanNameReply(cleanTypeName(rpc.ReturnsType)),
+					ParamType:   "*" + param,
+					ParamName:   toLowerCamelCase(param),
+					ReturnType:  "*" + result,
 					Comment:     comment,
-				})
-				bizData.Entities = append(bizData.Entities, &BizEntity{
-					Name: cleanNameRequest(cleanTypeName(rpc.RequestType)),
+					Page:        base.ListPage(definition, rpc),
+					Mask:        base.UpdateMask(definition, rpc),
+					Write:       base.Writes(rpc.Name),
+					Operation:   base.Operation(s, rpc),
 				})
 			}
 		}),
 	)
-
-	// 检查目标目录是否存在
-	if _, err = os.Stat(targetDir); os.IsNotExist(err) {
-		fmt.Printf("Target directory: %s does not exist, creating...\n", targetDir)
-		if err = os.MkdirAll(targetDir, 0o755); err != nil {
-			log.Fatalf("failed to create target directory: %v", err)
+	bizData.Entities = entities.entities
+	for _, e := range bizData.Entities {
+		if err := e.buildValidation(); err != nil {
+			return nil, err
 		}
 	}
-	// 加载并解析 biz 层模板
-	tpl, err := template.New("bizTemplate").Funcs(template.FuncMap{
-		"toLower": strings.ToLower,
-	}).Parse(bizTemplate)
-	if err != nil {
-		log.Fatalf("failed to parse biz template: %v", err)
+	// Update 方法的字段掩码：校验请求实体中的字段路径
+	for _, m := range bizData.Methods {
+		if e := bizData.entity(strings.TrimPrefix(m.ParamType, "*")); e != nil && m.Mask != nil {
+			e.Mask = m.Mask
+		}
 	}
-
-	// 生成 biz 代码文件
-	// 生成文件名：小写服务名 + _ucase.go（kratos  biz 层命名规范）
-	filename := strings.ToLower(bizData.ServiceName) + ".go"
-	targetPath := filepath.Join(targetDir, filename)
-
-	// 检查文件是否已存在
-	if _, err = os.Stat(targetPath); !os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "biz file already exists: %s\n", targetPath)
-		return
+	// 有校验项、字段掩码或需要校验的嵌套实体时才生成 validate()，嵌套实体可能互相引用，迭代到不再变化
+	for changed := true; changed; {
+		changed = false
+		for _, e := range bizData.Entities {
+			if !e.Validate && (len(e.Checks) > 0 || e.Mask != nil || e.nestedValidate(bizData)) {
+				e.Validate, changed = true, true
+			}
+		}
 	}
+	return bizData, nil
+}
 
-	// 创建文件并渲染模板
-	file, err := os.Create(targetPath)
+// render 渲染 biz 层模板
+func render(text string, bizData *BizData) ([]byte, error) {
+	// 加载并解析模板
+	tpl, err := template.New("bizTemplate").Funcs(template.FuncMap{
+		"toLower": strings.ToLower,
+		// elem 去掉指针类型的 *，如 *User → User
+		"elem": func(s string) string { return strings.TrimPrefix(s, "*") },
+	}).Parse(text)
 	if err != nil {
-		log.Fatalf("failed to create biz file: %v", err)
+		return nil, fmt.Errorf("failed to parse biz template: %v", err)
 	}
-	defer file.Close()
 
-	if err := tpl.Execute(file, bizData); err != nil {
-		log.Fatalf("failed to render biz template: %v", err)
+	// 渲染模板
+	buf := new(bytes.Buffer)
+	if err := tpl.Execute(buf, bizData); err != nil {
+		return nil, fmt.Errorf("failed to render biz template: %v", err)
 	}
-
-	fmt.Printf("generated biz file: %s\n", targetPath)
+	return format.Source(buf.Bytes())
 }
 
 // ------------------------------
@@ -138,25 +308,167 @@ type BizData struct {
 	ServiceName string       // 服务名（大驼峰）
 	Methods     []*BizMethod // 方法列表
 	Entities    []*BizEntity // 实体列表
+	Instrument  bool         // 是否生成 OpenTelemetry 埋点
+	Tx          bool         // UseCase 是否依赖 Transaction 事务接口
+}
+
+// Imports 返回实体校验、字段类型与埋点需要的标准库
+func (d *BizData) Imports() []string {
+	var pkgs []string
+	seen := make(map[string]bool)
+	add := func(pkg string) {
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	if d.Instrument {
+		add("time")
+	}
+	for _, e := range d.Entities {
+		for _, f := range e.Fields {
+			if strings.Contains(f.FieldType, "time.") {
+				add("time")
+			}
+		}
+		for _, pkg := range e.Imports {
+			add(pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// Paginated 报告是否有分页的 List 方法
+func (d *BizData) Paginated() bool {
+	for _, m := range d.Methods {
+		if m.Page != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Validated 报告是否有实体需要校验，决定是否引入 kratos errors
+func (d *BizData) Validated() bool {
+	for _, e := range d.Entities {
+		if len(e.Checks) > 0 || e.Mask != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Example 返回满足校验规则的实体示例，如 &CreateUser{Name: "a"}
+func (d *BizData) Example(name string) string {
+	return d.example(name, 0)
+}
+
+func (d *BizData) example(name string, depth int) string {
+	e := d.entity(name)
+	if e == nil || depth > 8 {
+		return "new(" + name + ")"
+	}
+	var values []string
+	for _, f := range e.Fields {
+		v := f.Example
+		if f.Entity != "" && v == "&"+f.Entity+"{}" {
+			v = d.example(f.Entity, depth+1)
+		}
+		if v != "" {
+			values = append(values, f.FieldName+": "+v)
+		}
+	}
+	if len(values) == 0 {
+		return "new(" + name + ")"
+	}
+	return "&" + name + "{" + strings.Join(values, ", ") + "}"
+}
+
+// Incomplete 报告实体（含嵌套实体）的示例是否无法满足全部校验规则
+func (d *BizData) Incomplete(name string) bool {
+	e := d.entity(name)
+	if e == nil {
+		return false
+	}
+	if e.Incomplete {
+		return true
+	}
+	for _, f := range e.Nested {
+		if f.Example != "" && f.Entity != name && d.Incomplete(f.Entity) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates 报告实体是否生成了 validate() 方法
+func (d *BizData) Validates(name string) bool {
+	e := d.entity(name)
+	return e != nil && e.Validate
+}
+
+func (d *BizData) entity(name string) *BizEntity {
+	for _, e := range d.Entities {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
 }
 
 type BizMethod struct {
-	ServiceName string // 服务名
-	MethodName  string // 方法名
-	ParamType   string // 参数类型
-	ParamName   string // 参数名
-	ReturnType  string // 返回类型
-	Comment     string // 注释
+	ServiceName string     // 服务名
+	MethodName  string     // 方法名
+	ParamType   string     // 参数类型
+	ParamName   string     // 参数名
+	ReturnType  string     // 返回类型
+	Comment     string     // 注释
+	Page        *base.Page // List 方法的分页字段，非分页方法为 nil
+	Mask        *base.Mask // Update 方法的字段掩码，没有时为 nil
+	Write       bool       // 是否为写操作（Create/Update/Delete），--tx 时在事务中调用 Repo
+	Operation   string     // proto 服务名/方法名（如 User/CreateUser），各层 span 的名称
 }
 
 type BizEntity struct {
-	Name   string // 实体名
-	Fields []*BizField
+	Name       string // 实体名
+	Fields     []*BizField
+	Checks     []*BizCheck   // 字段校验
+	Patterns   []*BizPattern // 字段校验使用的正则表达式
+	Nested     []*BizField   // 需要递归校验的实体字段
+	Imports    []string      // 校验需要的标准库
+	Incomplete bool          // 测试示例无法满足全部校验规则
+	Mask       *base.Mask    // 字段掩码，校验其中的路径是否为资源的字段
+	Validate   bool          // 是否生成 validate() 方法
+}
+
+// nestedValidate 报告是否有嵌套实体需要校验
+func (e *BizEntity) nestedValidate(d *BizData) bool {
+	for _, f := range e.Nested {
+		if d.Validates(f.Entity) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskVar 返回字段掩码允许路径的包级变量名，如 updateUserMaskPaths
+func (e *BizEntity) MaskVar() string {
+	return toLowerCamelCase(e.Name) + "MaskPaths"
 }
 
 type BizField struct {
 	FieldName string // 字段名
 	FieldType string // 字段类型
+	Comment   string // 注释
+	Entity    string // message 类型字段对应的实体名
+	Repeated  bool   // 是否为 repeated 字段
+	Map       bool   // 是否为 map 字段
+	Example   string // 满足校验规则的测试示例值，空表示零值
+
+	protoName string          // proto 字段名
+	protoType string          // proto 字段类型
+	options   []*proto.Option // proto 字段选项（校验规则）
 }
 
 // serviceName 服务名/方法名转大驼峰
@@ -179,18 +491,6 @@ func cleanTypeName(name string) string {
 	return parts[len(parts)-1]
 }
 
-func cleanNameRequestAndToLowerCamelCase(name string) string {
-	return toLowerCamelCase(strings.TrimSuffix(name, "Request"))
-}
-
-func cleanNameRequest(name string) string {
-	return strings.TrimSuffix(name, "Request")
-}
-
-func cleanNameReply(name string) string {
-	return strings.TrimSuffix(name, "Reply")
-}
-
 // toLowerCamelCase 下划线转小驼峰（如 user_create → userCreate）
 func toLowerCamelCase(s string) string {
 	if len(s) > 0 {
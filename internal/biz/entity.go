@@ -0,0 +1,185 @@
+package biz
+
+import (
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// scalarTypes proto 标量类型对应的 Go 类型
+var scalarTypes = map[string]string{
+	"double":   "float64",
+	"float":    "float32",
+	"int32":    "int32",
+	"sint32":   "int32",
+	"sfixed32": "int32",
+	"int64":    "int64",
+	"sint64":   "int64",
+	"sfixed64": "int64",
+	"uint32":   "uint32",
+	"fixed32":  "uint32",
+	"uint64":   "uint64",
+	"fixed64":  "uint64",
+	"bool":     "bool",
+	"string":   "string",
+	"bytes":    "[]byte",
+}
+
+// wellKnownTypes 常用 google.protobuf 类型对应的 Go 类型
+var wellKnownTypes = map[string]string{
+	"google.protobuf.Timestamp":   "time.Time",
+	"google.protobuf.Duration":    "time.Duration",
+	"google.protobuf.FieldMask":   "[]string",
+	"google.protobuf.Struct":      "map[string]any",
+	"google.protobuf.StringValue": "*string",
+	"google.protobuf.BoolValue":   "*bool",
+	"google.protobuf.Int32Value":  "*int32",
+	"google.protobuf.Int64Value":  "*int64",
+	"google.protobuf.UInt32Value": "*uint32",
+	"google.protobuf.UInt64Value": "*uint64",
+	"google.protobuf.FloatValue":  "*float32",
+	"google.protobuf.DoubleValue": "*float64",
+	"google.protobuf.BytesValue":  "[]byte",
+}
+
+// entityBuilder 根据 proto message 构造 biz 领域实体
+type entityBuilder struct {
+	messages map[string]*proto.Message // proto message，按名称索引
+	enums    map[string]bool           // proto 枚举名
+	entities []*BizEntity
+	byName   map[string]*BizEntity
+	added    map[*proto.Message]bool // 已生成实体的 message，避免递归重复
+}
+
+func newEntityBuilder(definition *proto.Proto) *entityBuilder {
+	b := &entityBuilder{
+		messages: make(map[string]*proto.Message),
+		enums:    make(map[string]bool),
+		byName:   make(map[string]*BizEntity),
+		added:    make(map[*proto.Message]bool),
+	}
+	proto.Walk(definition,
+		proto.WithMessage(func(m *proto.Message) {
+			b.messages[m.Name] = m
+		}),
+		proto.WithEnum(func(e *proto.Enum) {
+			b.enums[e.Name] = true
+		}),
+	)
+	return b
+}
+
+// add 添加名为 name 的实体，字段来自 proto 类型 typeName 对应的 message
+// 实体已存在时（多个方法使用同一个 message）不重复添加
+func (b *entityBuilder) add(name, typeName string) {
+	if _, ok := b.byName[name]; ok {
+		return
+	}
+	e := &BizEntity{Name: name}
+	b.byName[name] = e
+	b.entities = append(b.entities, e)
+	m := b.messages[cleanTypeName(typeName)]
+	if m == nil || b.added[m] {
+		return
+	}
+	b.added[m] = true
+	for _, f := range messageFields(m) {
+		if e.field(toUpperCamelCase(f.Name)) != nil {
+			continue
+		}
+		field := b.field(f)
+		if field == nil {
+			// 无法映射的类型（如其它文件中定义的 message）不生成字段
+			continue
+		}
+		e.Fields = append(e.Fields, field)
+	}
+}
+
+// field 返回 proto 字段对应的实体字段，message 类型的字段同时生成对应的实体
+func (b *entityBuilder) field(f *protoField) *BizField {
+	typ, entity := b.goType(f.Type)
+	if typ == "" {
+		return nil
+	}
+	if f.KeyType != "" {
+		key := scalarTypes[f.KeyType]
+		if key == "" {
+			return nil
+		}
+		typ = "map[" + key + "]" + typ
+	} else if f.Repeated {
+		typ = "[]" + typ
+	}
+	comment := f.Name
+	if f.Comment != nil {
+		comment = strings.TrimSpace(f.Comment.Message())
+	}
+	field := &BizField{
+		FieldName: toUpperCamelCase(f.Name),
+		FieldType: typ,
+		Comment:   comment,
+		Entity:    entity,
+		Repeated:  f.Repeated,
+		Map:       f.KeyType != "",
+		protoName: f.Name,
+		protoType: f.Type,
+		options:   f.Options,
+	}
+	return field
+}
+
+// goType 返回 proto 类型对应的 Go 类型，message 类型同时返回实体名
+func (b *entityBuilder) goType(typeName string) (string, string) {
+	if t, ok := scalarTypes[typeName]; ok {
+		return t, ""
+	}
+	if t, ok := wellKnownTypes[strings.TrimPrefix(typeName, ".")]; ok {
+		return t, ""
+	}
+	name := cleanTypeName(typeName)
+	if b.enums[name] {
+		return "int32", ""
+	}
+	if _, ok := b.messages[name]; ok {
+		b.add(name, name)
+		return "*" + name, name
+	}
+	return "", ""
+}
+
+// protoField proto message 的字段（普通字段、oneof 字段与 map 字段）
+type protoField struct {
+	*proto.Field
+	Repeated bool
+	KeyType  string // map 字段的 key 类型
+}
+
+// messageFields 返回 message 的字段，包含 oneof 中的字段
+func messageFields(m *proto.Message) []*protoField {
+	var fields []*protoField
+	for _, e := range m.Elements {
+		switch f := e.(type) {
+		case *proto.NormalField:
+			fields = append(fields, &protoField{Field: f.Field, Repeated: f.Repeated})
+		case *proto.MapField:
+			fields = append(fields, &protoField{Field: f.Field, KeyType: f.KeyType})
+		case *proto.Oneof:
+			for _, oe := range f.Elements {
+				if of, ok := oe.(*proto.OneOfField); ok {
+					fields = append(fields, &protoField{Field: of.Field})
+				}
+			}
+		}
+	}
+	return fields
+}
+
+func (e *BizEntity) field(name string) *BizField {
+	for _, f := range e.Fields {
+		if f.FieldName == name {
+			return f
+		}
+	}
+	return nil
+}
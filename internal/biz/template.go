@@ -5,7 +5,14 @@ package biz
 
 import (
 	"context"
+	{{- range .Imports }}
+	"{{ . }}"
+	{{- end }}
+
+	{{- if .Validated }}
 
+	"github.com/go-kratos/kratos/v2/errors"
+	{{- end }}
 	"github.com/go-kratos/kratos/v2/log"
 )
 
@@ -16,37 +23,232 @@ type {{ .Name }} struct {
 	{{ .FieldName }} {{ .FieldType }} // {{ .Comment }}
 	{{- end }}
 }
+{{- if .Patterns }}
+
+var (
+	{{- range .Patterns }}
+	{{ .Var }} = regexp.MustCompile({{ .Expr }})
+	{{- end }}
+)
+{{- end }}
+{{- if .Mask }}
+
+// {{ .MaskVar }} {{ .Mask.Name }} 中允许的字段路径（{{ .Mask.Type }} 的字段）
+var {{ .MaskVar }} = map[string]bool{
+	{{- range .Mask.Paths }}
+	{{ printf "%q" . }}: true,
+	{{- end }}
+}
+{{- end }}
+{{- if .Validate }}
+
+// validate 按 proto 中的校验规则校验 {{ .Name }}，失败时返回 BadRequest 错误
+func (x *{{ .Name }}) validate() error {
+	if x == nil {
+		return nil
+	}
+	{{- range .Checks }}
+	if {{ .Cond }} {
+		return errors.BadRequest("VALIDATOR", {{ printf "%q" .Message }})
+	}
+	{{- end }}
+	{{- if .Mask }}
+	for _, path := range x.{{ .Mask.Field }} {
+		if !{{ .MaskVar }}[path] {
+			return errors.BadRequest("VALIDATOR", "{{ .Mask.Name }}: field path "+path+" does not exist on {{ .Mask.Type }}")
+		}
+	}
+	{{- end }}
+	{{- range .Nested }}
+	{{- if not ($.Validates .Entity) }}
+	{{- else if .Repeated }}
+	for _, v := range x.{{ .FieldName }} {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+	{{- else }}
+	if err := x.{{ .FieldName }}.validate(); err != nil {
+		return err
+	}
+	{{- end }}
+	{{- end }}
+	return nil
+}
+{{- end }}
 {{- end }}
 
 type {{ .ServiceName }}Repo interface {
 	{{- range .Methods }}
 	// {{ .Comment }}
-	{{ .MethodName }}(ctx context.Context{{- if .ParamName }}, {{ .ParamName }} {{ .ParamType }} {{ end }}) ({{ .ReturnType }}, error) 
+	{{ .MethodName }}(ctx context.Context{{- if .ParamName }}, {{ .ParamName }} {{ .ParamType }}{{ end }}{{ if .Page }}, opts *ListOptions{{ end }}) ({{ .ReturnType }}, error) 
 	{{- end }}
 }
 
 type {{ .ServiceName }}UseCase struct {
 	repo {{ .ServiceName }}Repo       // 依赖 Repo 接口（依赖抽象）
+	{{- if .Tx }}
+	tx   Transaction                  // 事务：在 uc.tx.InTx 中调用多个 Repo 方法
+	{{- end }}
 	log  *log.Helper                  // 日志组件
 }
 
-func New{{ .ServiceName }}UseCase(repo {{ .ServiceName }}Repo, logger log.Logger) *{{ .ServiceName }}UseCase {
+func New{{ .ServiceName }}UseCase(repo {{ .ServiceName }}Repo, {{ if .Tx }}tx Transaction, {{ end }}logger log.Logger) *{{ .ServiceName }}UseCase {
 	return &{{ .ServiceName }}UseCase{
 		repo: repo,
+		{{- if .Tx }}
+		tx:   tx,
+		{{- end }}
 		log:  log.NewHelper(log.With(logger, "module", "usecase/{{ .ServiceName | toLower }}")),
 	}
 }
 
 {{ range .Methods }}
 // {{ .Comment }}
-func (uc *{{ $.ServiceName }}UseCase) {{ .MethodName }}(ctx context.Context{{- if .ParamName }}, {{ .ParamName }} {{ .ParamType }} {{ end }}) ({{ .ReturnType }}, error) {
-	data, err := uc.repo.{{ .MethodName }}(ctx{{- if .ParamName }}, {{ .ParamName }}{{ end }})
+func (uc *{{ $.ServiceName }}UseCase) {{ .MethodName }}(ctx context.Context{{- if .ParamName }}, {{ .ParamName }} {{ .ParamType }} {{ end }}) ({{ if $.Instrument }}_ {{ end }}{{ .ReturnType }}, {{ if $.Instrument }}err {{ end }}error) {
+	{{- if $.Instrument }}
+	ctx, span := tracer.Start(ctx, "{{ .Operation }}")
+	defer observe(ctx, span, "{{ .Operation }}", time.Now(), &err)
+	{{ end }}
+	{{- if and .ParamName ($.Validates (.ParamType | elem)) }}
+	if err := {{ .ParamName }}.validate(); err != nil {
+		return nil, err
+	}
+	{{- end }}
+	{{- if .Page }}
+	{{- if .Page.Token }}
+	opts, err := newListOptions(int({{ .ParamName }}.{{ .Page.Size }}), {{ .ParamName }}.{{ .Page.Token }})
+	if err != nil {
+		return nil, err
+	}
+	{{- else }}
+	opts := newPageOptions(int({{ .ParamName }}.{{ .Page.Number }}), int({{ .ParamName }}.{{ .Page.Size }}))
+	{{- end }}
+	{{- end }}
+	{{- if and $.Tx .Write }}
+	// 写操作在事务中执行，fn 中通过 ctx 调用的 Repo 方法使用同一个事务
+	var data {{ .ReturnType }}
+	err {{ if not $.Instrument }}:{{ end }}= uc.tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		data, err = uc.repo.{{ .MethodName }}(ctx{{- if .ParamName }}, {{ .ParamName }}{{ end }})
+		return err
+	})
+	{{- else }}
+	data, err := uc.repo.{{ .MethodName }}(ctx{{- if .ParamName }}, {{ .ParamName }}{{ end }}{{ if .Page }}, opts{{ end }})
+	{{- end }}
 	if err != nil {
 		uc.log.Errorf("{{ .MethodName }} repo operation failed: %v", err)
 		return nil, err
 	}
+	{{- if and .Page .Page.NextToken .Page.Items }}
+	data.{{ .Page.NextToken }} = opts.NextPageToken(len(data.{{ .Page.Items }}))
+	{{- end }}
 
 	return data, nil
 }
 {{- end }}
 `
+
+var bizTestTemplate = `
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// fake{{ .ServiceName }}Repo 手写的 {{ .ServiceName }}Repo 实现，每个方法的行为由对应的函数字段决定
+type fake{{ .ServiceName }}Repo struct {
+	{{- range .Methods }}
+	{{ .MethodName }}Func func(ctx context.Context{{- if .ParamName }}, {{ .ParamName }} {{ .ParamType }}{{ end }}{{ if .Page }}, opts *ListOptions{{ end }}) ({{ .ReturnType }}, error)
+	{{- end }}
+}
+{{ range .Methods }}
+func (f *fake{{ $.ServiceName }}Repo) {{ .MethodName }}(ctx context.Context{{- if .ParamName }}, {{ .ParamName }} {{ .ParamType }}{{ end }}{{ if .Page }}, opts *ListOptions{{ end }}) ({{ .ReturnType }}, error) {
+	if f.{{ .MethodName }}Func == nil {
+		panic("unexpected call to {{ $.ServiceName }}Repo.{{ .MethodName }}")
+	}
+	return f.{{ .MethodName }}Func(ctx{{- if .ParamName }}, {{ .ParamName }}{{ end }}{{ if .Page }}, opts{{ end }})
+}
+{{ end }}
+{{- if .Tx }}
+// fake{{ .ServiceName }}Transaction 不开启事务，直接执行 fn
+type fake{{ .ServiceName }}Transaction struct{}
+
+func (fake{{ .ServiceName }}Transaction) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+{{ end }}
+{{- range .Methods }}
+func Test{{ $.ServiceName }}UseCase_{{ .MethodName }}(t *testing.T) {
+	{{- if and .ParamName ($.Incomplete (.ParamType | elem)) }}
+	// TODO: 补全请求参数后删除 t.Skip，部分校验规则（如正则）无法自动生成满足条件的值
+	t.Skip("the generated {{ .ParamType | elem }} does not satisfy every validation rule")
+	{{- end }}
+	errRepo := errors.New("repo failed")
+	tests := []struct {
+		name    string
+		reply   {{ .ReturnType }}
+		repoErr error
+	}{
+		{name: "success", reply: new({{ .ReturnType | elem }})},
+		{name: "repo error", repoErr: errRepo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fake{{ $.ServiceName }}Repo{
+				{{ .MethodName }}Func: func(ctx context.Context{{- if .ParamName }}, {{ .ParamName }} {{ .ParamType }}{{ end }}{{ if .Page }}, opts *ListOptions{{ end }}) ({{ .ReturnType }}, error) {
+					return tt.reply, tt.repoErr
+				},
+			}
+			uc := New{{ $.ServiceName }}UseCase(repo, {{ if $.Tx }}fake{{ $.ServiceName }}Transaction{}, {{ end }}log.DefaultLogger)
+
+			got, err := uc.{{ .MethodName }}(context.Background(){{- if .ParamName }}, {{ $.Example (.ParamType | elem) }}{{ end }})
+			if !errors.Is(err, tt.repoErr) {
+				t.Fatalf("{{ .MethodName }}() error = %v, want %v", err, tt.repoErr)
+			}
+			if tt.repoErr != nil {
+				if got != nil {
+					t.Errorf("{{ .MethodName }}() = %v, want nil on error", got)
+				}
+				return
+			}
+			if got != tt.reply {
+				t.Errorf("{{ .MethodName }}() = %v, want %v", got, tt.reply)
+			}
+		})
+	}
+}
+{{ end }}`
+
+var bizErrorsTemplate = `
+package biz
+
+import (
+	"github.com/go-kratos/kratos/v2/errors"
+
+	{{ .GoName }} "{{ .GoPackage }}"
+)
+
+// 由 proto 错误原因生成的业务错误，data 层返回、service 层透传给调用方
+var (
+	{{- range .Reasons }}
+	// {{ .Var }} {{ if .Comment }}{{ .Comment }}{{ else }}{{ .Message }}{{ end }}
+	{{ .Var }} = errors.{{ if .Func }}{{ .Func }}({{ else }}New({{ .Code }}, {{ end }}{{ $.GoName }}.{{ .Enum }}_{{ .Name }}.String(), "{{ .Message }}")
+	{{- end }}
+)
+`
+
+var bizTransactionTemplate = `package biz
+
+import "context"
+
+// Transaction 事务接口，由 data 层实现
+// fn 中使用传入的 ctx 调用 Repo 方法，这些方法在同一个事务中执行；fn 返回错误时回滚
+type Transaction interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+`
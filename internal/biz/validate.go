@@ -0,0 +1,400 @@
+package biz
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// 校验规则的 proto 选项前缀：PGV 与 protovalidate
+var ruleOptions = []string{"(validate.rules)", "(buf.validate.field)"}
+
+// numberRules 数值类型的规则名（PGV 与 protovalidate 相同）
+var numberRules = map[string]bool{
+	"int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true, "float": true, "double": true,
+}
+
+const (
+	uuidPattern  = `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`
+	emailPattern = `^[^@\s]+@[^@\s]+\.[^@\s]+$`
+)
+
+// rule 一条校验规则，如 string.min_len = 1
+type rule struct {
+	path  string // 去掉选项前缀的规则路径，如 string.min_len、message.required、required
+	value string // 规则值，字符串已去掉转义
+}
+
+// fieldRules 返回字段选项中的校验规则，聚合写法 {min_len: 1, max_len: 64} 展开为多条规则
+func fieldRules(options []*proto.Option) []rule {
+	var rules []rule
+	for _, o := range options {
+		for _, prefix := range ruleOptions {
+			if o.Name == prefix || strings.HasPrefix(o.Name, prefix+".") {
+				path := strings.TrimPrefix(strings.TrimPrefix(o.Name, prefix), ".")
+				rules = flattenRule(path, &o.Constant, rules)
+			}
+		}
+	}
+	return rules
+}
+
+func flattenRule(path string, lit *proto.Literal, rules []rule) []rule {
+	if len(lit.OrderedMap) > 0 {
+		for _, nl := range lit.OrderedMap {
+			p := nl.Name
+			if path != "" {
+				p = path + "." + nl.Name
+			}
+			rules = flattenRule(p, nl.Literal, rules)
+		}
+		return rules
+	}
+	value := lit.Source
+	if lit.IsString {
+		if s, err := strconv.Unquote(`"` + lit.Source + `"`); err == nil {
+			value = s
+		}
+	}
+	return append(rules, rule{path: path, value: value})
+}
+
+// BizCheck 一项字段校验
+type BizCheck struct {
+	Cond    string // 校验失败时为 true 的 Go 表达式
+	Message string // 校验失败的错误信息
+}
+
+// BizPattern 字段校验使用的正则表达式
+type BizPattern struct {
+	Var  string // 包级变量名
+	Expr string // 正则表达式的 Go 字符串字面量
+}
+
+// buildValidation 根据字段的校验规则生成实体的校验项与测试示例值，规则值与字段类型不符时返回错误
+func (e *BizEntity) buildValidation() error {
+	for _, f := range e.Fields {
+		x := "x." + f.FieldName
+		rules := fieldRules(f.options)
+		ignoreEmpty := false
+		for _, r := range rules {
+			switch {
+			case r.value != "true":
+			case strings.HasSuffix(r.path, ".ignore_empty"), r.path == "ignore_empty":
+				ignoreEmpty = true
+			}
+			if r.path == "ignore" && strings.HasPrefix(r.value, "IGNORE_IF_") {
+				ignoreEmpty = true
+			}
+		}
+		example := newExample(f)
+		for _, r := range rules {
+			cond, message, err := e.check(f, x, r, example)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", e.Name, f.protoName, err)
+			}
+			if cond == "" {
+				continue
+			}
+			if ignoreEmpty && !f.required(rules) {
+				cond = nonZero(f, x) + " && " + cond
+			}
+			e.Checks = append(e.Checks, &BizCheck{Cond: cond, Message: f.protoName + ": " + message})
+		}
+		if f.Entity != "" && !f.Map {
+			e.Nested = append(e.Nested, f)
+		}
+		f.Example = example.literal()
+		if example.incomplete && !(ignoreEmpty && !f.required(rules)) {
+			e.Incomplete = true
+		}
+	}
+	return nil
+}
+
+// check 返回规则的校验条件与错误信息，不支持的规则返回空条件，规则值不适用于字段类型时返回错误
+func (e *BizEntity) check(f *BizField, x string, r rule, ex *example) (string, string, error) {
+	kind, name, _ := strings.Cut(r.path, ".")
+	switch {
+	case r.path == "required" || r.path == "message.required":
+		if r.value != "true" {
+			return "", "", nil
+		}
+		ex.require()
+		return zero(f, x), "value is required", nil
+
+	case kind == "string" && f.FieldType == "string":
+		switch name {
+		case "min_len", "max_len", "len":
+			n, err := lengthValue(r)
+			if err != nil {
+				return "", "", err
+			}
+			e.imports("unicode/utf8")
+			switch name {
+			case "min_len":
+				ex.minLen(n)
+				return fmt.Sprintf("utf8.RuneCountInString(%s) < %d", x, n), fmt.Sprintf("value length must be at least %d characters", n), nil
+			case "max_len":
+				return fmt.Sprintf("utf8.RuneCountInString(%s) > %d", x, n), fmt.Sprintf("value length must be at most %d characters", n), nil
+			default:
+				ex.minLen(n)
+				return fmt.Sprintf("utf8.RuneCountInString(%s) != %d", x, n), fmt.Sprintf("value length must be %d characters", n), nil
+			}
+		case "pattern":
+			ex.incomplete = true
+			return "!" + e.pattern(f, "Pattern", r.value) + ".MatchString(" + x + ")", fmt.Sprintf("value does not match regex pattern %q", r.value), nil
+		case "email":
+			if r.value != "true" {
+				return "", "", nil
+			}
+			ex.set(strconv.Quote("user@example.com"))
+			return "!" + e.pattern(f, "Email", emailPattern) + ".MatchString(" + x + ")", "value must be a valid email address", nil
+		case "uuid":
+			if r.value != "true" {
+				return "", "", nil
+			}
+			ex.set(strconv.Quote("123e4567-e89b-42d3-a456-426614174000"))
+			return "!" + e.pattern(f, "UUID", uuidPattern) + ".MatchString(" + x + ")", "value must be a valid UUID", nil
+		}
+
+	case numberRules[kind] && !f.Repeated && !f.Map:
+		switch name {
+		case "gt", "gte", "lt", "lte", "const":
+		default:
+			return "", "", nil
+		}
+		if kind != f.protoType {
+			return "", "", fmt.Errorf("rule %s does not apply to %s field", r.path, f.protoType)
+		}
+		lit, v, err := numberValue(f.FieldType, r.value)
+		if err != nil {
+			return "", "", fmt.Errorf("rule %s = %s: %v", r.path, r.value, err)
+		}
+		switch name {
+		case "gt":
+			ex.atLeast(v + 1)
+			return fmt.Sprintf("%s <= %s", x, lit), "value must be greater than " + lit, nil
+		case "gte":
+			ex.atLeast(v)
+			return fmt.Sprintf("%s < %s", x, lit), "value must be greater than or equal to " + lit, nil
+		case "lt":
+			ex.atMost(v - 1)
+			return fmt.Sprintf("%s >= %s", x, lit), "value must be less than " + lit, nil
+		case "lte":
+			ex.atMost(v)
+			return fmt.Sprintf("%s > %s", x, lit), "value must be less than or equal to " + lit, nil
+		default:
+			ex.set(lit)
+			return fmt.Sprintf("%s != %s", x, lit), "value must equal " + lit, nil
+		}
+
+	case (kind == "repeated" && f.Repeated) || (kind == "map" && f.Map):
+		switch name {
+		case "min_items", "max_items", "min_pairs", "max_pairs":
+		default:
+			return "", "", nil
+		}
+		n, err := lengthValue(r)
+		if err != nil {
+			return "", "", err
+		}
+		switch name {
+		case "min_items", "min_pairs":
+			if n > 0 {
+				ex.incomplete = true
+			}
+			return fmt.Sprintf("len(%s) < %d", x, n), fmt.Sprintf("value must contain at least %d item(s)", n), nil
+		case "max_items", "max_pairs":
+			return fmt.Sprintf("len(%s) > %d", x, n), fmt.Sprintf("value must contain no more than %d item(s)", n), nil
+		}
+	}
+	return "", "", nil
+}
+
+// lengthValue 解析长度与个数规则的值，须为非负整数
+func lengthValue(r rule) (int, error) {
+	n, err := strconv.ParseUint(r.value, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("rule %s = %s: value must be a non-negative integer", r.path, r.value)
+	}
+	return int(n), nil
+}
+
+// numberValue 按字段的 Go 类型解析数值规则的值，返回 Go 字面量与数值
+// 小数规则用于整数字段、超出字段取值范围等不能表示为字段类型的值返回错误
+func numberValue(goType, value string) (string, float64, error) {
+	switch goType {
+	case "int32", "int64":
+		bits := 32
+		if goType == "int64" {
+			bits = 64
+		}
+		n, err := strconv.ParseInt(value, 0, bits)
+		if err != nil {
+			return "", 0, fmt.Errorf("value is not a valid %s", goType)
+		}
+		return strconv.FormatInt(n, 10), float64(n), nil
+	case "uint32", "uint64":
+		bits := 32
+		if goType == "uint64" {
+			bits = 64
+		}
+		n, err := strconv.ParseUint(value, 0, bits)
+		if err != nil {
+			return "", 0, fmt.Errorf("value is not a valid %s", goType)
+		}
+		return strconv.FormatUint(n, 10), float64(n), nil
+	case "float32", "float64":
+		bits := 32
+		if goType == "float64" {
+			bits = 64
+		}
+		v, err := strconv.ParseFloat(value, bits)
+		if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+			return "", 0, fmt.Errorf("value is not a finite %s", goType)
+		}
+		return strconv.FormatFloat(v, 'g', -1, bits), v, nil
+	}
+	return "", 0, fmt.Errorf("unsupported field type %s", goType)
+}
+
+// pattern 声明字段使用的正则表达式变量并返回变量名，如 userEmailPattern
+func (e *BizEntity) pattern(f *BizField, kind, expr string) string {
+	name := toLowerCamelCase(e.Name) + f.FieldName
+	if !strings.HasSuffix(name, kind) {
+		name += kind
+	}
+	if !strings.HasSuffix(name, "Pattern") {
+		name += "Pattern"
+	}
+	lit := strconv.Quote(expr)
+	if strconv.CanBackquote(expr) {
+		lit = "`" + expr + "`"
+	}
+	e.Patterns = append(e.Patterns, &BizPattern{Var: name, Expr: lit})
+	e.imports("regexp")
+	return name
+}
+
+func (e *BizEntity) imports(pkg string) {
+	for _, p := range e.Imports {
+		if p == pkg {
+			return
+		}
+	}
+	e.Imports = append(e.Imports, pkg)
+}
+
+// required 报告字段是否有必填规则
+func (f *BizField) required(rules []rule) bool {
+	for _, r := range rules {
+		if (r.path == "required" || r.path == "message.required") && r.value == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// zero 返回字段为零值（未填写）时为 true 的 Go 表达式
+func zero(f *BizField, x string) string {
+	switch t := f.FieldType; {
+	case t == "string":
+		return x + ` == ""`
+	case t == "bool":
+		return "!" + x
+	case t == "time.Time":
+		return x + ".IsZero()"
+	case strings.HasPrefix(t, "*"):
+		return x + " == nil"
+	case strings.HasPrefix(t, "[]"), strings.HasPrefix(t, "map["):
+		return "len(" + x + ") == 0"
+	default:
+		return x + " == 0"
+	}
+}
+
+// nonZero 返回字段已填写时为 true 的 Go 表达式
+func nonZero(f *BizField, x string) string {
+	switch t := f.FieldType; {
+	case t == "string":
+		return x + ` != ""`
+	case t == "bool":
+		return x
+	case t == "time.Time":
+		return "!" + x + ".IsZero()"
+	case strings.HasPrefix(t, "*"):
+		return x + " != nil"
+	case strings.HasPrefix(t, "[]"), strings.HasPrefix(t, "map["):
+		return "len(" + x + ") != 0"
+	default:
+		return x + " != 0"
+	}
+}
+
+func isNumber(t string) bool {
+	return strings.HasPrefix(t, "int") || strings.HasPrefix(t, "uint") || strings.HasPrefix(t, "float")
+}
+
+// example 满足字段校验规则的测试示例值
+type example struct {
+	field      *BizField
+	value      string // Go 字面量，空表示零值
+	incomplete bool   // 无法自动构造满足规则的值
+	num        *float64
+}
+
+func newExample(f *BizField) *example {
+	return &example{field: f}
+}
+
+func (ex *example) set(v string) {
+	ex.value = v
+}
+
+func (ex *example) minLen(n int) {
+	if len(ex.value) < n+2 {
+		ex.value = strconv.Quote(strings.Repeat("a", n))
+	}
+}
+
+func (ex *example) atLeast(v float64) {
+	if ex.num == nil || *ex.num < v {
+		ex.num = &v
+	}
+}
+
+func (ex *example) atMost(v float64) {
+	if ex.num == nil && v < 0 {
+		ex.num = &v
+	}
+}
+
+// require 为必填字段构造非零值
+func (ex *example) require() {
+	switch t := ex.field.FieldType; {
+	case t == "string":
+		ex.minLen(1)
+	case t == "bool":
+		ex.value = "true"
+	case isNumber(t):
+		ex.atLeast(1)
+	case ex.field.Entity != "" && !ex.field.Repeated && !ex.field.Map:
+		// 由 BizData.example 替换为嵌套实体的示例
+		ex.value = "&" + ex.field.Entity + "{}"
+	default:
+		ex.incomplete = true
+	}
+}
+
+func (ex *example) literal() string {
+	if ex.num != nil && ex.value == "" {
+		return strconv.FormatFloat(*ex.num, 'f', -1, 64)
+	}
+	return ex.value
+}
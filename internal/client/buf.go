@@ -0,0 +1,156 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	bufYAML    = "buf.yaml"
+	bufGenYAML = "buf.gen.yaml"
+)
+
+// bufDeps replace the kratos module include paths when there is no local third_party directory.
+var bufDeps = []string{
+	"buf.build/googleapis/googleapis",
+	"buf.build/envoyproxy/protoc-gen-validate",
+	"buf.build/bufbuild/protovalidate",
+	"buf.build/kratos/apis",
+}
+
+// prepareBuf writes buf.yaml and buf.gen.yaml unless they already exist.
+// An existing buf.gen.yaml runs its own plugins, so the plugin flags are rejected rather than silently ignored.
+func prepareBuf() error {
+	if _, err := exec.LookPath("buf"); err != nil {
+		return fmt.Errorf("buf is not installed, see https://buf.build/docs/installation")
+	}
+	if flags := pluginFlags(); len(flags) > 0 && pathExists(bufGenYAML) {
+		return fmt.Errorf("%s already configures the buf plugins, edit it or remove it to use %s", bufGenYAML, strings.Join(flags, ", "))
+	}
+	if !pathExists(bufYAML) {
+		body, deps := bufConfig()
+		if err := os.WriteFile(bufYAML, body, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("generated %s\n", bufYAML)
+		if deps {
+			if err := runBuf("dep", "update"); err != nil {
+				return err
+			}
+		}
+	}
+	if !pathExists(bufGenYAML) {
+		var outs []output
+		for _, o := range pluginOutputs {
+			if o.Import == "" {
+				outs = append(outs, o)
+			}
+		}
+		if err := os.WriteFile(bufGenYAML, bufGenConfig(outs), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("generated %s\n", bufGenYAML)
+	}
+	return nil
+}
+
+// pluginFlags returns the flags changing the plugin outputs that are set.
+func pluginFlags() []string {
+	var flags []string
+	for name, set := range map[string]bool{
+		"--config":         configFile != "",
+		"--plugin":         len(enablePlugins) > 0,
+		"--disable-plugin": len(disablePlugins) > 0,
+		"--plugin-opt":     len(pluginOpts) > 0,
+		"--plugin-out":     len(pluginOuts) > 0,
+	} {
+		if set {
+			flags = append(flags, name)
+		}
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// bufConfig returns the buf.yaml matching the protoc include paths, and whether it depends on remote modules.
+func bufConfig() ([]byte, bool) {
+	buf := new(bytes.Buffer)
+	buf.WriteString("version: v2\n")
+	include := filepath.ToSlash(filepath.Clean(protoPath))
+	if pathExists(protoPath) && !filepath.IsAbs(include) && !strings.HasPrefix(include, "..") {
+		fmt.Fprintf(buf, "modules:\n  - path: .\n    excludes:\n      - %s\n  - path: %s\n", include, include)
+		return buf.Bytes(), false
+	}
+	buf.WriteString("modules:\n  - path: .\ndeps:\n")
+	for _, d := range bufDeps {
+		fmt.Fprintf(buf, "  - %s\n", d)
+	}
+	return buf.Bytes(), true
+}
+
+// bufGenConfig returns the buf.gen.yaml running the given plugin outputs.
+func bufGenConfig(outs []output) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("version: v2\nplugins:\n")
+	for _, o := range outs {
+		fmt.Fprintf(buf, "  - local: protoc-gen-%s\n    out: %s\n", o.Name, o.dir())
+		if o.Opt != "" {
+			fmt.Fprintf(buf, "    opt: %s\n", o.Opt)
+		}
+	}
+	return buf.Bytes()
+}
+
+// generateBuf runs buf generate for the proto files.
+func generateBuf(protos ...string) error {
+	paths := make([]string, 0, 2*len(protos))
+	for _, p := range protos {
+		paths = append(paths, "--path", p)
+	}
+	if err := runBuf(append([]string{"generate", "--template", bufGenYAML}, paths...)...); err != nil {
+		return err
+	}
+	conf, err := os.ReadFile(bufGenYAML)
+	if err != nil {
+		return err
+	}
+	// plugins depending on the imports only run on the matching protos, like with protoc
+	for _, o := range outputs(protos[0]) {
+		if o.Import == "" || bytes.Contains(conf, []byte("protoc-gen-"+o.Name)) {
+			continue
+		}
+		if err := runBuf(append([]string{"generate", "--template", bufTemplate(o)}, paths...)...); err != nil {
+			return err
+		}
+	}
+	printf("proto: %s\n", strings.Join(protos, " "))
+	return nil
+}
+
+// bufTemplate returns an inline buf.gen.yaml running the given plugin outputs.
+func bufTemplate(outs ...output) string {
+	type plugin struct {
+		Local string `json:"local"`
+		Out   string `json:"out"`
+		Opt   string `json:"opt,omitempty"`
+	}
+	tmpl := struct {
+		Version string   `json:"version"`
+		Plugins []plugin `json:"plugins"`
+	}{Version: "v2"}
+	for _, o := range outs {
+		tmpl.Plugins = append(tmpl.Plugins, plugin{Local: "protoc-gen-" + o.Name, Out: o.dir(), Opt: o.Opt})
+	}
+	b, _ := json.Marshal(tmpl)
+	return string(b)
+}
+
+func runBuf(args ...string) error {
+	return command("buf", args...)
+}
@@ -1,14 +1,16 @@
 package client
 
 import (
-	"bufio"
-	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
 )
@@ -21,103 +23,248 @@ var CmdClient = &cobra.Command{
 	Run:   run,
 }
 
-var protoPath string
+// Engines running the plugins.
+const (
+	engineProtoc = "protoc"
+	engineBuf    = "buf"
+)
+
+var (
+	protoPath      string
+	install        bool
+	engine         string
+	jobs           int
+	includes       []string
+	excludes       []string
+	ignoreFile     string
+	force          bool
+	check          bool
+	configFile     string
+	enablePlugins  []string
+	disablePlugins []string
+	pluginOpts     []string
+	pluginOuts     []string
+
+	// pluginOutputs are the plugin outputs resolved from the config and flags.
+	pluginOutputs []output
+)
 
 func init() {
 	if protoPath = os.Getenv("KRATOS_PROTO_PATH"); protoPath == "" {
 		protoPath = "./third_party"
 	}
 	CmdClient.Flags().StringVarP(&protoPath, "proto_path", "p", protoPath, "proto path")
+	CmdClient.Flags().BoolVar(&install, "install-plugins", false, "install the missing protoc plugins with go install")
+	CmdClient.Flags().StringVar(&engine, "engine", engineProtoc, "generation engine: protoc or buf")
+	CmdClient.Flags().StringSliceVar(&includes, "include", nil, "only generate the proto files matching these globs in directory mode, e.g. api/**/v1/*.proto")
+	CmdClient.Flags().StringSliceVar(&excludes, "exclude", nil, "skip the paths matching these globs in directory mode, e.g. api/internal/")
+	CmdClient.Flags().StringVar(&ignoreFile, "ignore-file", defaultIgnoreFile, ".gitignore style file of the paths skipped in directory mode")
+	CmdClient.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "number of proto packages generated concurrently in directory mode")
+	CmdClient.Flags().BoolVar(&force, "force", false, "regenerate all the proto files, even the unchanged ones")
+	CmdClient.Flags().BoolVar(&check, "check", false, "only check that the generated code is up to date, exit 1 otherwise")
+	CmdClient.Flags().StringVar(&configFile, "config", "", "plugin config file (default "+defaultConfig+" if it exists)")
+	CmdClient.Flags().StringSliceVar(&enablePlugins, "plugin", nil, "enable extra plugins, e.g. go-vtproto,connect-go")
+	CmdClient.Flags().StringSliceVar(&disablePlugins, "disable-plugin", nil, "disable plugins, e.g. openapi,go-errors")
+	CmdClient.Flags().StringArrayVar(&pluginOpts, "plugin-opt", nil, "append a plugin option, e.g. go-grpc=require_unimplemented_servers=false")
+	CmdClient.Flags().StringArrayVar(&pluginOuts, "plugin-out", nil, "set a plugin output directory, e.g. openapi=docs")
 }
 
 func run(_ *cobra.Command, args []string) {
+	if err := generateArgs(args); err != nil {
+		fmt.Println(err)
+		if check {
+			os.Exit(1)
+		}
+	}
+}
+
+// generateArgs installs the plugins and generates the proto file or directory of the arguments.
+// Every failure is returned so that run exits with an error in check mode.
+func generateArgs(args []string) error {
+	if install {
+		if err := installPlugins(); err != nil {
+			return err
+		}
+	}
 	if len(args) == 0 {
-		fmt.Println("Please enter the proto file or directory")
-		return
+		if install {
+			return nil
+		}
+		return errors.New("Please enter the proto file or directory")
 	}
 	var (
 		err   error
 		proto = strings.TrimSpace(args[0])
 	)
-	if err = look("protoc-gen-go", "protoc-gen-go-grpc", "protoc-gen-go-http", "protoc-gen-go-errors", "protoc-gen-openapi"); err != nil {
-		// update the kratos plugins
-		cmd := exec.Command("kratos", "upgrade")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err = cmd.Run(); err != nil {
-			fmt.Println(err)
-			return
+	if pluginOutputs, err = loadOutputs(); err != nil {
+		return err
+	}
+	if engine != engineProtoc && engine != engineBuf {
+		return fmt.Errorf("unknown engine %q, expected protoc or buf", engine)
+	}
+	files := []string{proto}
+	if !strings.HasSuffix(proto, ".proto") {
+		if files, err = walk(proto); err != nil {
+			return err
 		}
 	}
-	if strings.HasSuffix(proto, ".proto") {
-		err = generate(proto, args)
-	} else {
-		err = walk(proto, args)
+	if missing := missingPlugins(pluginOutputs, files); len(missing) > 0 {
+		return fmt.Errorf("missing protoc plugins: %s, run with --install-plugins to install the known ones", strings.Join(missing, ", "))
 	}
+	return generateFiles(files)
+}
+
+// Generate generates the client code of the proto files of the directory that changed since the last generation,
+// with the default flags of the client command. It is meant to be called repeatedly, e.g. by the watch command.
+func Generate(dir string) error {
+	// the protos may have changed since the previous call
+	importCache.Clear()
+	outs, err := loadOutputs()
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
+	files, err := walk(dir)
+	if err != nil {
+		return err
+	}
+	if missing := missingPlugins(outs, files); len(missing) > 0 {
+		return fmt.Errorf("missing protoc plugins: %s, run proto client --install-plugins to install the known ones", strings.Join(missing, ", "))
+	}
+	pluginOutputs = outs
+	return generateFiles(files)
 }
 
-func look(name ...string) error {
-	for _, n := range name {
-		if _, err := exec.LookPath(n); err != nil {
+// generateFiles generates the files that changed since the last generation and records them in the lock file.
+// In check mode nothing is generated and an error lists the stale files.
+func generateFiles(files []string) error {
+	l, err := readLock()
+	if err != nil {
+		return err
+	}
+	plugins, err := pluginsFingerprint(pluginOutputs)
+	if err != nil {
+		return err
+	}
+	stale, hashes, err := staleFiles(l, plugins, files, force)
+	if err != nil {
+		return err
+	}
+	if check {
+		if len(stale) > 0 {
+			return fmt.Errorf("generated code is stale, run proto client to regenerate:\n  %s", strings.Join(stale, "\n  "))
+		}
+		fmt.Println("generated code is up to date")
+		return nil
+	}
+	if len(stale) == 0 {
+		fmt.Println("generated code is up to date, use --force to regenerate")
+		return nil
+	}
+	if engine == engineBuf {
+		if err := prepareBuf(); err != nil {
 			return err
 		}
 	}
-	return nil
+	generated, genErr := generateGroups(groupFiles(stale), jobs)
+	done := make(map[string]bool, len(generated))
+	for _, f := range generated {
+		done[f] = true
+	}
+	if l.Plugins != plugins {
+		// the protos missing from this run were generated with the previous plugins and are stale too
+		l.Protos = make(map[string]string)
+	}
+	for _, f := range stale {
+		if done[f] {
+			l.Protos[lockKey(f)] = hashes[f]
+		} else {
+			delete(l.Protos, lockKey(f))
+		}
+	}
+	l.Plugins = plugins
+	if err := l.write(); err != nil {
+		return err
+	}
+	return genErr
 }
 
-func walk(dir string, args []string) error {
+// walk returns the proto files of the directory.
+// Hidden and vendor directories are skipped, as well as the excluded and ignored paths.
+func walk(dir string) ([]string, error) {
 	if dir == "" {
 		dir = "."
 	}
-	return filepath.Walk(dir, func(path string, _ os.FileInfo, _ error) error {
-		if ext := filepath.Ext(path); ext != ".proto" || strings.HasPrefix(path, "third_party") {
+	ignores, err := readIgnoreFile(ignoreFile, ignoreFile != defaultIgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+	defaults := []string{"/third_party/"}
+	if p := filepath.ToSlash(filepath.Clean(protoPath)); !filepath.IsAbs(p) && !strings.HasPrefix(p, "..") {
+		defaults = append(defaults, "/"+p+"/")
+	}
+	exclude, err := newMatcher(append(append(defaults, excludes...), ignores...))
+	if err != nil {
+		return nil, err
+	}
+	include, err := newMatcher(includes)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Clean(path))
+		if d.IsDir() {
+			if name != "." && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor" || exclude.match(name, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".proto" || exclude.match(name, false) {
+			return nil
+		}
+		if len(include) > 0 && !include.match(name, false) {
 			return nil
 		}
-		return generate(path, args)
+		files = append(files, path)
+		return nil
 	})
+	return files, err
 }
 
-// generate is used to execute the generate command for the specified proto file
-func generate(proto string, args []string) error {
-	input := []string{
-		"--proto_path=.",
-	}
-	if pathExists(protoPath) {
-		input = append(input, "--proto_path="+protoPath)
-	}
-	inputExt := []string{
-		"--proto_path=" + kratosMod(),
-		"--proto_path=" + filepath.Join(kratosMod(), "third_party"),
-		"--go_out=paths=source_relative:.",
-		"--go-grpc_out=paths=source_relative:.",
-		"--go-http_out=paths=source_relative:.",
-		"--go-errors_out=paths=source_relative:.",
-		"--openapi_out=paths=source_relative:.",
-	}
-	input = append(input, inputExt...)
-	protoBytes, err := os.ReadFile(proto)
-	if err == nil && len(protoBytes) > 0 {
-		if ok, _ := regexp.Match(`\n[^/]*(import)\s+"validate/validate.proto"`, protoBytes); ok {
-			input = append(input, "--validate_out=lang=go,paths=source_relative:.")
+// outputs returns the plugin outputs of the proto file.
+// Plugins with an import only run when the proto imports that file.
+func outputs(proto string) []output {
+	var outs []output
+	for _, o := range pluginOutputs {
+		if o.Import == "" || importsFile(proto, o.Import) {
+			outs = append(outs, o)
 		}
 	}
-	input = append(input, proto)
-	for _, a := range args {
-		if strings.HasPrefix(a, "-") {
-			input = append(input, a)
-		}
+	return outs
+}
+
+// generate is used to execute the generate command for the specified proto files.
+// The files must share the same proto package and plugin outputs.
+func generate(protos ...string) error {
+	if engine == engineBuf {
+		return generateBuf(protos...)
+	}
+	checkProtovalidate(protos)
+	var input []string
+	for _, p := range includePaths() {
+		input = append(input, "--proto_path="+p)
 	}
-	fd := exec.Command("protoc", input...)
-	fd.Stdout = os.Stdout
-	fd.Stderr = os.Stderr
-	fd.Dir = "."
-	if err := fd.Run(); err != nil {
+	for _, o := range outputs(protos[0]) {
+		input = append(input, o.protocArg())
+	}
+	input = append(input, protos...)
+	if err := command("protoc", input...); err != nil {
 		return err
 	}
-	fmt.Printf("proto: %s\n", proto)
+	printf("proto: %s\n", strings.Join(protos, " "))
 	return nil
 }
 
@@ -129,43 +276,33 @@ func pathExists(path string) bool {
 	return true
 }
 
-// kratosMod returns kratos mod.
-func kratosMod() string {
-	// go 1.15+ read from env GOMODCACHE
-	cacheOut, _ := exec.Command("go", "env", "GOMODCACHE").Output()
-	cachePath := strings.Trim(string(cacheOut), "\n")
-	pathOut, _ := exec.Command("go", "env", "GOPATH").Output()
-	gopath := strings.Trim(string(pathOut), "\n")
-	if cachePath == "" {
-		cachePath = filepath.Join(gopath, "pkg", "mod")
-	}
-	if path, err := moduleVersion("github.com/go-kratos/kratos/v2"); err == nil {
-		// $GOPATH/pkg/mod/github.com/go-kratos/kratos@v2
-		return filepath.Join(cachePath, path)
-	}
-	// $GOPATH/src/github.com/go-kratos/kratos
-	return filepath.Join(gopath, "src", "github.com", "go-kratos", "kratos")
-}
+const kratosModule = "github.com/go-kratos/kratos/v2"
 
-// moduleVersion returns module version.
-func moduleVersion(path string) (string, error) {
-	stdout := &bytes.Buffer{}
-	fd := exec.Command("go", "mod", "graph")
-	fd.Stdout = stdout
-	fd.Stderr = stdout
-	if err := fd.Run(); err != nil {
-		return "", err
-	}
-	rd := bufio.NewReader(stdout)
-	for {
-		line, _, err := rd.ReadLine()
-		if err != nil {
-			return "", err
+// kratosMod returns kratos mod.
+// It is resolved once per invocation and reused for every generated file.
+var kratosMod = sync.OnceValue(func() string {
+	out, err := exec.Command("go", "list", "-m", "-json", kratosModule).Output()
+	if err == nil {
+		var m struct {
+			Path    string
+			Version string
+			Dir     string
 		}
-		str := string(line)
-		i := strings.Index(str, "@")
-		if strings.Contains(str, path+"@") && i != -1 {
-			return path + str[i:], nil
+		if err = json.Unmarshal(out, &m); err == nil {
+			if m.Dir != "" {
+				return m.Dir
+			}
+			if m.Version != "" {
+				// $GOMODCACHE/github.com/go-kratos/kratos/v2@version, not downloaded yet
+				return filepath.Join(goEnv("GOMODCACHE"), m.Path+"@"+m.Version)
+			}
 		}
 	}
+	// $GOPATH/src/github.com/go-kratos/kratos
+	return filepath.Join(goEnv("GOPATH"), "src", "github.com", "go-kratos", "kratos")
+})
+
+func goEnv(key string) string {
+	out, _ := exec.Command("go", "env", key).Output()
+	return strings.TrimSpace(string(out))
 }
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfig is the config file read when --config is not set.
+const defaultConfig = "kratos-client.yaml"
+
+// output is the output of a protoc plugin.
+type output struct {
+	Name    string `yaml:"name"`    // plugin name without the protoc-gen- prefix
+	Opt     string `yaml:"opt"`     // plugin options
+	Out     string `yaml:"out"`     // output directory, defaults to .
+	Import  string `yaml:"import"`  // only run the plugin on protos importing this file
+	Disable bool   `yaml:"disable"` // remove a default plugin
+}
+
+// config is the plugin config of the client generation, e.g.
+//
+//	plugins:
+//	  - name: go-grpc
+//	    opt: paths=source_relative,require_unimplemented_servers=false
+//	  - name: openapi
+//	    disable: true
+//	  - name: go-vtproto
+//	    opt: paths=source_relative,features=marshal+unmarshal+size
+type config struct {
+	Plugins []output `yaml:"plugins"`
+}
+
+// defaultOutputs are the plugin outputs used without any config.
+var defaultOutputs = []output{
+	{Name: "go", Opt: "paths=source_relative"},
+	{Name: "go-grpc", Opt: "paths=source_relative"},
+	{Name: "go-http", Opt: "paths=source_relative"},
+	{Name: "go-errors", Opt: "paths=source_relative"},
+	{Name: "openapi", Opt: "paths=source_relative"},
+	{Name: "validate", Opt: "lang=go,paths=source_relative", Import: pgvImport},
+}
+
+// loadOutputs returns the default outputs updated by the config file and the plugin flags.
+func loadOutputs() ([]output, error) {
+	outs := append([]output(nil), defaultOutputs...)
+	name, required := configFile, true
+	if name == "" {
+		name, required = defaultConfig, false
+	}
+	b, err := os.ReadFile(name)
+	switch {
+	case err == nil:
+		var c config
+		if err := yaml.Unmarshal(b, &c); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		for _, o := range c.Plugins {
+			if o.Name == "" {
+				return nil, fmt.Errorf("%s: plugin without name", name)
+			}
+			outs = setOutput(outs, o)
+		}
+	case !os.IsNotExist(err) || required:
+		return nil, err
+	}
+	for _, n := range enablePlugins {
+		if index(outs, n) == -1 {
+			outs = append(outs, output{Name: n, Opt: "paths=source_relative"})
+		}
+	}
+	for _, kv := range pluginOpts {
+		n, opt, ok := strings.Cut(kv, "=")
+		i := index(outs, n)
+		if !ok || i == -1 {
+			return nil, fmt.Errorf("invalid --plugin-opt %q, expected an enabled plugin name=option", kv)
+		}
+		outs[i].Opt = strings.Trim(outs[i].Opt+","+opt, ",")
+	}
+	for _, kv := range pluginOuts {
+		n, dir, ok := strings.Cut(kv, "=")
+		i := index(outs, n)
+		if !ok || i == -1 {
+			return nil, fmt.Errorf("invalid --plugin-out %q, expected an enabled plugin name=dir", kv)
+		}
+		outs[i].Out = dir
+	}
+	res := outs[:0]
+	for _, o := range outs {
+		if !o.Disable && !slices.Contains(disablePlugins, o.Name) {
+			res = append(res, o)
+		}
+	}
+	return res, nil
+}
+
+// setOutput merges the output into the one of the same plugin or appends it.
+func setOutput(outs []output, o output) []output {
+	i := index(outs, o.Name)
+	if i == -1 {
+		return append(outs, o)
+	}
+	if o.Opt != "" {
+		outs[i].Opt = o.Opt
+	}
+	if o.Out != "" {
+		outs[i].Out = o.Out
+	}
+	if o.Import != "" {
+		outs[i].Import = o.Import
+	}
+	outs[i].Disable = o.Disable
+	return outs
+}
+
+func index(outs []output, name string) int {
+	for i, o := range outs {
+		if o.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// protocArg returns the protoc argument of the output, e.g. --go_out=paths=source_relative:.
+func (o output) protocArg() string {
+	if o.Opt == "" {
+		return "--" + o.Name + "_out=" + o.dir()
+	}
+	return "--" + o.Name + "_out=" + o.Opt + ":" + o.dir()
+}
+
+func (o output) dir() string {
+	if o.Out == "" {
+		return "."
+	}
+	return o.Out
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// outputMu serializes the output of the concurrent generations.
+var outputMu sync.Mutex
+
+// group is a set of proto files generated by a single protoc invocation.
+type group struct {
+	Dir   string
+	Files []string
+}
+
+// groupFiles groups the proto files by directory, as protoc requires the files of a package together.
+// Files of a directory are split further when they need different plugin outputs.
+func groupFiles(files []string) []*group {
+	index := make(map[string]*group)
+	var groups []*group
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		key := dir
+		for _, o := range outputs(f) {
+			key += "\x00" + o.Name
+		}
+		g, ok := index[key]
+		if !ok {
+			g = &group{Dir: dir}
+			index[key] = g
+			groups = append(groups, g)
+		}
+		g.Files = append(g.Files, f)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Dir < groups[j].Dir })
+	return groups
+}
+
+// generateGroups generates the groups with up to jobs concurrent invocations.
+// It returns the successfully generated files and the errors of all the failed groups.
+func generateGroups(groups []*group, jobs int) ([]string, error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		errs      []error
+		generated []string
+		sem       = make(chan struct{}, jobs)
+	)
+	for _, g := range groups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(g *group) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := generate(g.Files...)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", g.Dir, err))
+				return
+			}
+			generated = append(generated, g.Files...)
+		}(g)
+	}
+	wg.Wait()
+	if len(errs) == 0 {
+		return generated, nil
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return generated, fmt.Errorf("%d of %d proto packages failed:\n%w", len(errs), len(groups), errors.Join(errs...))
+}
+
+// command runs the command, writing its output at once so that concurrent runs do not interleave.
+func command(name string, args ...string) error {
+	fd := exec.Command(name, args...)
+	fd.Dir = "."
+	out, err := fd.CombinedOutput()
+	if len(out) > 0 {
+		outputMu.Lock()
+		os.Stdout.Write(out)
+		outputMu.Unlock()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func printf(format string, a ...any) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Printf(format, a...)
+}
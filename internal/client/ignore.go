@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultIgnoreFile is the ignore file read when --ignore-file is not set.
+const defaultIgnoreFile = ".kratosignore"
+
+// pattern is a .gitignore style pattern.
+type pattern struct {
+	re     *regexp.Regexp
+	negate bool // re-includes the paths matched by the previous patterns
+	dir    bool // only matches directories
+}
+
+// compilePattern compiles a .gitignore style pattern:
+// patterns without a slash match at any depth, "*" does not cross directories and "**" does.
+func compilePattern(s string) (*pattern, error) {
+	p := new(pattern)
+	if strings.HasPrefix(s, "!") {
+		p.negate = true
+		s = s[1:]
+	}
+	if strings.HasSuffix(s, "/") {
+		p.dir = true
+		s = strings.TrimRight(s, "/")
+	}
+	s = strings.TrimPrefix(s, "./")
+	var b strings.Builder
+	b.WriteString("^")
+	if strings.HasPrefix(s, "/") {
+		s = s[1:]
+	} else if !strings.Contains(s, "/") {
+		b.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case strings.HasPrefix(s[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(s[i:], "/**") && i+3 == len(s):
+			b.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(s[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid pattern %q: unterminated [", s)
+			}
+			class := s[i : i+end+1]
+			if strings.HasPrefix(class, "[!") {
+				class = "[^" + class[2:]
+			}
+			b.WriteString(class)
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", s, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// matcher is an ordered list of patterns, the last matching pattern wins.
+type matcher []*pattern
+
+func newMatcher(patterns []string) (matcher, error) {
+	var m matcher
+	for _, s := range patterns {
+		if s = strings.TrimSpace(s); s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		p, err := compilePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, p)
+	}
+	return m, nil
+}
+
+// readIgnoreFile returns the patterns of the ignore file, ignoring a missing optional file.
+func readIgnoreFile(name string, required bool) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines, sc.Err()
+}
+
+// match reports whether the slash separated path is matched by the patterns.
+func (m matcher) match(path string, isDir bool) bool {
+	matched := false
+	for _, p := range m {
+		if p.dir && !isDir {
+			continue
+		}
+		if p.re.MatchString(path) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
@@ -0,0 +1,81 @@
+package client
+
+import "testing"
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		dir     bool
+		want    bool
+	}{
+		{pattern: "internal", path: "internal", dir: true, want: true},
+		{pattern: "internal", path: "api/internal", dir: true, want: true},
+		{pattern: "internal", path: "api/internal.proto", want: false},
+		{pattern: "/internal", path: "internal", dir: true, want: true},
+		{pattern: "/internal", path: "api/internal", dir: true, want: false},
+		{pattern: "internal/", path: "api/internal", dir: true, want: true},
+		{pattern: "internal/", path: "api/internal", want: false},
+		{pattern: "./api/internal/", path: "api/internal", dir: true, want: true},
+		{pattern: "*.proto", path: "api/v1/user.proto", want: true},
+		{pattern: "api/*.proto", path: "api/user.proto", want: true},
+		{pattern: "api/*.proto", path: "api/v1/user.proto", want: false},
+		{pattern: "api/**/*.proto", path: "api/user.proto", want: true},
+		{pattern: "api/**/*.proto", path: "api/user/v1/user.proto", want: true},
+		{pattern: "api/**/v1/*.proto", path: "api/user/v2/user.proto", want: false},
+		{pattern: "api/**", path: "api/user/v1/user.proto", want: true},
+		{pattern: "api/**", path: "apis/user.proto", want: false},
+		{pattern: "**/testdata", path: "api/user/testdata", dir: true, want: true},
+		{pattern: "api/v?/*.proto", path: "api/v1/user.proto", want: true},
+		{pattern: "api/v?/*.proto", path: "api/v10/user.proto", want: false},
+		{pattern: "api/v[12]/*.proto", path: "api/v2/user.proto", want: true},
+		{pattern: "api/v[!12]/*.proto", path: "api/v2/user.proto", want: false},
+		{pattern: "api/v[!12]/*.proto", path: "api/v3/user.proto", want: true},
+		{pattern: "user.v1.proto", path: "user_v1_proto", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			m, err := newMatcher([]string{tt.pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.match(tt.path, tt.dir); got != tt.want {
+				t.Errorf("match() = %v, want %v (%s)", got, tt.want, m[0].re)
+			}
+		})
+	}
+}
+
+func TestCompilePatternError(t *testing.T) {
+	if _, err := compilePattern("api/v[12/*.proto"); err == nil {
+		t.Error("compilePattern() error = nil, want unterminated [")
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m, err := newMatcher([]string{
+		"# generated protos",
+		"",
+		"api/**/internal/",
+		"!api/user/internal/",
+		"*_test.proto",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		dir  bool
+		want bool
+	}{
+		{path: "api/order/internal", dir: true, want: true},
+		{path: "api/user/internal", dir: true, want: false},
+		{path: "api/user/v1/user_test.proto", want: true},
+		{path: "api/user/v1/user.proto", want: false},
+	}
+	for _, tt := range tests {
+		if got := m.match(tt.path, tt.dir); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
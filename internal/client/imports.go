@@ -0,0 +1,121 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/emicklei/proto"
+	"golang.org/x/mod/semver"
+)
+
+// pgvImport is the file of the PGV rules, which need protoc-gen-validate.
+const pgvImport = "validate/validate.proto"
+
+// protovalidateImport is the file of the protovalidate rules. They are evaluated at runtime
+// by protovalidate-go and need no generator, only the proto file to compile the imports.
+const protovalidateImport = "buf/validate/validate.proto"
+
+// protovalidateHelp tells where to get the protovalidate rules when they are not found.
+const protovalidateHelp = "copy proto/protovalidate/buf of https://github.com/bufbuild/protovalidate into the proto path, " +
+	"run go mod download github.com/bufbuild/protovalidate or use --engine=buf"
+
+// importCache caches the parsed imports as they are read by several steps of a generation.
+var importCache sync.Map
+
+// importsFile reports whether the proto file imports the given file.
+// Protos that fail to parse are reported by protoc itself.
+func importsFile(proto, file string) bool {
+	imports, ok := importCache.Load(proto)
+	if !ok {
+		list, err := parseImports(proto)
+		if err != nil {
+			return false
+		}
+		imports, _ = importCache.LoadOrStore(proto, list)
+	}
+	return slices.Contains(imports.([]string), file)
+}
+
+// parseImports returns the files imported by the proto file.
+func parseImports(file string) ([]string, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	definition, err := proto.NewParser(reader).Parse()
+	if err != nil {
+		return nil, err
+	}
+	var imports []string
+	proto.Walk(definition, proto.WithImport(func(i *proto.Import) {
+		imports = append(imports, i.Filename)
+	}))
+	return imports, nil
+}
+
+// includePaths returns the directories imports are resolved from, in protoc order.
+func includePaths() []string {
+	paths := []string{"."}
+	if pathExists(protoPath) {
+		paths = append(paths, protoPath)
+	}
+	mod := kratosMod()
+	paths = append(paths, mod, filepath.Join(mod, "third_party"))
+	if p := protovalidatePath(); p != "" {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// protovalidatePath returns the directory of the protovalidate rules in the module cache, or "" when it is not downloaded.
+// The latest downloaded version is used.
+var protovalidatePath = sync.OnceValue(func() string {
+	dirs, _ := filepath.Glob(filepath.Join(goEnv("GOMODCACHE"), "github.com", "bufbuild", "protovalidate@*", "proto", "protovalidate"))
+	slices.SortFunc(dirs, func(a, b string) int {
+		return semver.Compare(moduleVersion(a), moduleVersion(b))
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if pathExists(filepath.Join(dirs[i], protovalidateImport)) {
+			return dirs[i]
+		}
+	}
+	return ""
+})
+
+// moduleVersion returns the version of a module cache directory, e.g. v1.0.0 for .../protovalidate@v1.0.0/proto.
+func moduleVersion(dir string) string {
+	_, v, _ := strings.Cut(filepath.ToSlash(dir), "@")
+	v, _, _ = strings.Cut(v, "/")
+	return v
+}
+
+// checkProtovalidate warns once when a proto imports the protovalidate rules that are not found in the include paths.
+func checkProtovalidate(protos []string) {
+	for _, p := range protos {
+		if importsFile(p, protovalidateImport) {
+			protovalidateOnce.Do(func() {
+				if resolveImport(protovalidateImport) == "" {
+					printf("warning: %s imports %s which is not found, %s\n", p, protovalidateImport, protovalidateHelp)
+				}
+			})
+			return
+		}
+	}
+}
+
+var protovalidateOnce sync.Once
+
+// resolveImport returns the file of the import, or "" when it is not found in the include paths,
+// e.g. the well-known types bundled with protoc.
+func resolveImport(name string) string {
+	for _, dir := range includePaths() {
+		if f := filepath.Join(dir, name); pathExists(f) {
+			return f
+		}
+	}
+	return ""
+}
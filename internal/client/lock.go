@@ -0,0 +1,162 @@
+package client
+
+import (
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// lockFile records what the generated code of each proto was generated from.
+const lockFile = "kratos-client.lock"
+
+// lock is the content of the lock file.
+type lock struct {
+	// Plugins is the fingerprint of the plugin binaries and their options.
+	Plugins string `json:"plugins"`
+	// Protos maps each generated proto to the hash of its content and transitive imports.
+	Protos map[string]string `json:"protos"`
+}
+
+func readLock() (*lock, error) {
+	l := &lock{Protos: make(map[string]string)}
+	b, err := os.ReadFile(lockFile)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("%s: %v", lockFile, err)
+	}
+	if l.Protos == nil {
+		l.Protos = make(map[string]string)
+	}
+	return l, nil
+}
+
+func (l *lock) write() error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lockFile, append(b, '\n'), 0o644)
+}
+
+// pluginsFingerprint returns a hash of the engine, the plugin versions and their options.
+// The version is read from the build info of the binary, falling back to a hash of the binary.
+// The plugins with an import are only required by the protos importing that file: a missing one is recorded as such.
+func pluginsFingerprint(outs []output) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "engine=%s\n", engine)
+	for _, o := range outs {
+		name := "protoc-gen-" + o.Name
+		version := "missing"
+		path, err := exec.LookPath(name)
+		switch {
+		case err == nil:
+			if version, err = binaryVersion(path); err != nil {
+				return "", err
+			}
+		case o.Import == "":
+			return "", err
+		}
+		fmt.Fprintf(h, "%s %s opt=%s out=%s import=%s\n", name, version, o.Opt, o.dir(), o.Import)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func binaryVersion(path string) (string, error) {
+	if info, err := buildinfo.ReadFile(path); err == nil && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Path + "@" + info.Main.Version, nil
+	}
+	return fileHash(path)
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hasher hashes proto files together with their transitive imports.
+type hasher struct {
+	hashes map[string]string
+}
+
+func newHasher() *hasher {
+	return &hasher{hashes: make(map[string]string)}
+}
+
+// hash returns the hash of the proto file content and of the files it imports, recursively.
+func (h *hasher) hash(file string) (string, error) {
+	if sum, ok := h.hashes[file]; ok {
+		return sum, nil
+	}
+	// guards against import cycles, which protoc rejects anyway
+	h.hashes[file] = ""
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	imports, err := parseImports(file)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", file, err)
+	}
+	sort.Strings(imports)
+	sum := sha256.New()
+	sum.Write(content)
+	for _, name := range imports {
+		dep := resolveImport(name)
+		if dep == "" {
+			continue
+		}
+		depSum, err := h.hash(dep)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(sum, "\n%s %s", name, depSum)
+	}
+	h.hashes[file] = hex.EncodeToString(sum.Sum(nil))
+	return h.hashes[file], nil
+}
+
+// staleFiles returns the files whose hash differs from the lock, along with the current hashes.
+// All files are stale when the plugins changed or force is set: the lock then only keeps the files generated
+// with the current plugins, see generateFiles.
+func staleFiles(l *lock, plugins string, files []string, force bool) ([]string, map[string]string, error) {
+	h := newHasher()
+	var (
+		stale  []string
+		hashes = make(map[string]string, len(files))
+	)
+	for _, f := range files {
+		sum, err := h.hash(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes[f] = sum
+		if force || l.Plugins != plugins || l.Protos[lockKey(f)] != sum {
+			stale = append(stale, f)
+		}
+	}
+	return stale, hashes, nil
+}
+
+func lockKey(file string) string {
+	return strings.TrimPrefix(strings.ReplaceAll(file, "\\", "/"), "./")
+}
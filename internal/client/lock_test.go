@@ -0,0 +1,140 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeProtos(t *testing.T, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	t.Chdir(t.TempDir())
+	l, err := readLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Plugins != "" || len(l.Protos) != 0 {
+		t.Fatalf("readLock() = %+v, want an empty lock without lock file", l)
+	}
+	l.Plugins = "fingerprint"
+	l.Protos["api/v1/a.proto"] = "hash"
+	if err := l.write(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Plugins != "fingerprint" || got.Protos["api/v1/a.proto"] != "hash" {
+		t.Errorf("readLock() = %+v, want the written lock", got)
+	}
+	if err := os.WriteFile(lockFile, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readLock(); err == nil {
+		t.Error("readLock() error = nil, want the invalid lock file")
+	}
+}
+
+func TestStaleFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeProtos(t, map[string]string{
+		"api/common/c.proto": "syntax = \"proto3\";\npackage c;\nmessage M {}\n",
+		"api/v1/a.proto":     "syntax = \"proto3\";\npackage a.v1;\nimport \"api/common/c.proto\";\n",
+		"api/v1/b.proto":     "syntax = \"proto3\";\npackage a.v1;\nimport \"google/protobuf/empty.proto\";\n",
+	})
+	files := []string{"./api/common/c.proto", "./api/v1/a.proto", "./api/v1/b.proto"}
+	stale, hashes, err := staleFiles(&lock{Protos: map[string]string{}}, "p1", files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(stale, files) {
+		t.Fatalf("staleFiles() = %v, want all the files without lock", stale)
+	}
+	l := &lock{Plugins: "p1", Protos: make(map[string]string)}
+	for f, sum := range hashes {
+		l.Protos[lockKey(f)] = sum
+	}
+	// a imports c, so it is stale when c changes
+	writeProtos(t, map[string]string{"api/common/c.proto": "syntax = \"proto3\";\npackage c;\nmessage M {}\nmessage N {}\n"})
+
+	tests := []struct {
+		name    string
+		plugins string
+		force   bool
+		want    []string
+	}{
+		{name: "changed import", plugins: "p1", want: []string{"./api/common/c.proto", "./api/v1/a.proto"}},
+		{name: "changed plugins", plugins: "p2", want: files},
+		{name: "force", plugins: "p1", force: true, want: files},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stale, _, err := staleFiles(l, tt.plugins, files, tt.force)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(stale, tt.want) {
+				t.Errorf("staleFiles() = %v, want %v", stale, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockKey(t *testing.T) {
+	tests := map[string]string{
+		"api/v1/a.proto":      "api/v1/a.proto",
+		"./api/v1/a.proto":    "api/v1/a.proto",
+		`api\v1\a.proto`:      "api/v1/a.proto",
+		`.\api\v1\a.proto`:    "api/v1/a.proto",
+		"../other/a.proto":    "../other/a.proto",
+		"/abs/api/v1/a.proto": "/abs/api/v1/a.proto",
+	}
+	for in, want := range tests {
+		if got := lockKey(in); got != want {
+			t.Errorf("lockKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPluginsFingerprint(t *testing.T) {
+	bin := t.TempDir()
+	for _, name := range []string{"protoc-gen-go", "protoc-gen-go-grpc"} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+	outs := []output{
+		{Name: "go", Opt: "paths=source_relative"},
+		{Name: "go-grpc", Opt: "paths=source_relative"},
+		{Name: "validate", Opt: "lang=go", Import: pgvImport},
+	}
+	fp, err := pluginsFingerprint(outs)
+	if err != nil {
+		t.Fatalf("pluginsFingerprint() error = %v, want the missing import gated plugin to be ignored", err)
+	}
+	again, err := pluginsFingerprint(outs)
+	if err != nil || again != fp {
+		t.Errorf("pluginsFingerprint() = %s, %v, want the stable %s", again, err, fp)
+	}
+	outs[1].Opt += ",require_unimplemented_servers=false"
+	if changed, _ := pluginsFingerprint(outs); changed == fp {
+		t.Error("pluginsFingerprint() did not change with the plugin options")
+	}
+	if _, err := pluginsFingerprint(append(outs, output{Name: "openapi"})); err == nil {
+		t.Error("pluginsFingerprint() error = nil, want the missing openapi plugin")
+	}
+}
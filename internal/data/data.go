@@ -1,14 +1,20 @@
 package data
 
 import (
+	"bytes"
 	"fmt"
+	"go/format"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"text/template"
 
+	"github.com/enneket/kratos-cli-boost/internal/base"
+
 	"github.com/emicklei/proto"
 	"github.com/spf13/cobra"
 	"golang.org/x/text/cases"
@@ -24,12 +30,28 @@ var CmdData = &cobra.Command{
 }
 
 var (
-	targetDir string // 生成目标目录
+	targetDir  string // 生成目标目录
+	withTests  bool   // 是否同时生成 Repo 测试
+	errorsFile string // 错误原因 proto 文件
+	dbPkg      string // 数据库访问包
+	instrument bool   // 是否生成 OpenTelemetry 埋点
+	withTx     bool   // 是否生成事务实现并让 Repo 使用 ctx 中的事务
+)
+
+// 支持的数据库访问包
+const (
+	dbSQL  = "database/sql"
+	dbGorm = "gorm.io/gorm"
 )
 
 // 初始化命令行参数
 func init() {
 	CmdData.Flags().StringVarP(&targetDir, "target-dir", "t", "internal/data", "generate target directory")
+	CmdData.Flags().BoolVar(&withTests, "with-tests", false, "also generate sqlmock tests of the Create, Get, Update (with its field mask), Delete and List methods with record fixtures")
+	CmdData.Flags().BoolVar(&instrument, "instrument", false, "start an OpenTelemetry span and record latency and error metrics in each Repo method")
+	CmdData.Flags().BoolVar(&withTx, "tx", false, "also implement biz.Transaction with the --db-pkg and make the Repo use the transaction carried by the context instead of its connection")
+	CmdData.Flags().StringVar(&errorsFile, "errors", "", "error reasons proto file to map the database errors to, e.g. api/xxx/v1/error_reason.proto")
+	CmdData.Flags().StringVar(&dbPkg, "db-pkg", dbSQL, "database package: "+dbSQL+" or "+dbGorm)
 }
 
 // 核心执行逻辑
@@ -40,10 +62,147 @@ func run(_ *cobra.Command, args []string) {
 		return
 	}
 
+	// 检查并创建目标目录
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		fmt.Printf("Target directory: %s does not exist, creating...\n", targetDir)
+		if err = os.MkdirAll(targetDir, 0o755); err != nil {
+			log.Fatalf("failed to create target directory: %v", err)
+		}
+	}
+
+	c, err := Generate(args[0], targetDir, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if c.Exists {
+		fmt.Fprintf(os.Stderr, "data file already exists: %s\n", c.File)
+	} else {
+		fmt.Printf("generated data file: %s\n", c.File)
+	}
+
+	if instrument {
+		if c, err = base.WriteInstrument(targetDir, "data", "data"); err != nil {
+			log.Fatal(err)
+		}
+		if !c.Exists {
+			fmt.Printf("generated data instrument file: %s\n", c.File)
+		}
+	}
+
+	if withTx {
+		if c, err = GenerateTransaction(args[0], targetDir); err != nil {
+			log.Fatal(err)
+		}
+		if c.Exists {
+			fmt.Fprintf(os.Stderr, "data transaction file already exists: %s\n", c.File)
+		} else {
+			fmt.Printf("generated data transaction file: %s\n", c.File)
+		}
+	}
+
+	if errorsFile != "" {
+		if c, err = GenerateErrors(args[0], errorsFile, targetDir); err != nil {
+			log.Fatal(err)
+		}
+		if c.Exists {
+			fmt.Fprintf(os.Stderr, "data errors file already exists: %s\n", c.File)
+		} else {
+			fmt.Printf("generated data errors file: %s\n", c.File)
+		}
+	}
+
+	if !withTests {
+		return
+	}
+	if c, err = GenerateTests(args[0], targetDir); err != nil {
+		log.Fatal(err)
+	}
+	if c.Exists {
+		fmt.Fprintf(os.Stderr, "data test file already exists: %s\n", c.File)
+		return
+	}
+	fmt.Printf("generated data test file: %s\n", c.File)
+}
+
+// Generate 生成 proto 文件对应的 data 层 Repo 实现到 dir 目录
+// 文件已存在时跳过；merge 为 true 时向已有文件补充缺失的方法
+func Generate(protoFile, dir string, merge bool) (*base.Change, error) {
+	dataData, err := parse(protoFile, dir)
+	if err != nil {
+		return nil, err
+	}
+	// 渲染 data 层模板
+	buf, err := render(dataTemplate, dataData)
+	if err != nil {
+		return nil, err
+	}
+	// 生成文件名：小写服务名 + .go
+	filename := strings.ToLower(dataData.Service) + ".go"
+	return base.WriteGo(filepath.Join(dir, filename), buf, merge)
+}
+
+// GenerateTests 生成 Repo 的测试：每个资源的记录 fixture + 每个标准方法基于 sqlmock 的测试，
+// 断言方法执行的 SQL 与参数，并比较查询结果与写入的记录；文件已存在时跳过
+func GenerateTests(protoFile, dir string) (*base.Change, error) {
+	dataData, err := parse(protoFile, dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataData.Resources()) == 0 {
+		return nil, fmt.Errorf("%s: no Create, Get, Update, Delete or List method of a message with an id field to test", protoFile)
+	}
+	buf, err := render(dataTestTemplate, dataData)
+	if err != nil {
+		return nil, err
+	}
+	// 生成文件名：小写服务名 + _test.go
+	filename := strings.ToLower(dataData.Service) + "_test.go"
+	return base.WriteGo(filepath.Join(dir, filename), buf, false)
+}
+
+// GenerateErrors 生成将数据库的记录不存在错误映射为 biz 层业务错误的函数
+// 使用错误原因 proto 中该服务资源的 404 错误（如 USER_NOT_FOUND）；文件已存在时跳过
+func GenerateErrors(protoFile, errorsProto, dir string) (*base.Change, error) {
+	dataData, err := parse(protoFile, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = dataData.mapErrors(errorsProto); err != nil {
+		return nil, err
+	}
+	buf, err := render(dataErrorsTemplate, dataData)
+	if err != nil {
+		return nil, err
+	}
+	// 生成文件名：小写服务名 + _errors.go
+	filename := strings.ToLower(dataData.Service) + "_errors.go"
+	return base.WriteGo(filepath.Join(dir, filename), buf, false)
+}
+
+// GenerateTransaction 生成 biz.Transaction 的实现（--db-pkg 对应的数据库事务）
+// 事务通过 ctx 传递，Repo 通过 db(ctx) 访问数据库时自动使用；文件已存在时跳过
+func GenerateTransaction(protoFile, dir string) (*base.Change, error) {
+	dataData, err := parse(protoFile, dir)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := render(dataTransactionTemplate, dataData)
+	if err != nil {
+		return nil, err
+	}
+	return base.WriteGo(filepath.Join(dir, "transaction.go"), buf, false)
+}
+
+// parse 解析 proto 文件，提取 data 层模板所需的服务与方法信息
+func parse(protoFile, dir string) (*DataData, error) {
+	if dbPkg != dbSQL && dbPkg != dbGorm {
+		return nil, fmt.Errorf("unknown --db-pkg %q, expected %s or %s", dbPkg, dbSQL, dbGorm)
+	}
+
 	// 打开 proto 文件
-	reader, err := os.Open(args[0])
+	reader, err := os.Open(protoFile)
 	if err != nil {
-		log.Fatalf("failed to open proto file: %v", err)
+		return nil, fmt.Errorf("failed to open proto file: %v", err)
 	}
 	defer reader.Close()
 
@@ -51,16 +210,23 @@ func run(_ *cobra.Command, args []string) {
 	parser := proto.NewParser(reader)
 	definition, err := parser.Parse()
 	if err != nil {
-		log.Fatalf("failed to parse proto file: %v", err)
+		return nil, fmt.Errorf("failed to parse proto file: %v", err)
+	}
+
+	// 领域层 UseCase 包的导入路径
+	bizPackage, err := base.ImportPath(convertToBizPackage(dir))
+	if err != nil {
+		return nil, err
 	}
 
 	// 提取 proto 关键信息（服务 + 方法，补充 PB 包路径）
-	dataData := &DataData{}
+	dataData := &DataData{Instrument: instrument, Tx: withTx, DBPkg: dbPkg}
+	names := base.NewEntityNames(definition)
 	proto.Walk(definition,
 		// 提取服务和方法（Repo 方法与 biz 层 UseCase 一一对应）
 		proto.WithService(func(s *proto.Service) {
-			dataData.Service = nameToUpperCamelCase(s.Name)          // 服务名
-			dataData.UseCasePackage = convertToBizPackage(targetDir) // 领域层 UseCase 包路径
+			dataData.Service = nameToUpperCamelCase(s.Name) // 服务名
+			dataData.UseCasePackage = bizPackage            // 领域层 UseCase 包路径
 			// 遍历 RPC 方法，生成 Repo 对应的实现方法
 			for _, e := range s.Elements {
 				rpc, ok := e.(*proto.RPC)
@@ -72,56 +238,161 @@ func run(_ *cobra.Command, args []string) {
 				if rpc.Comment != nil {
 					comment = rpc.Comment.Message()
 				}
-				// 添加方法信息
+				// 添加方法信息（实体类型来自 biz 包）
+				param, result := names.RPC(rpc)
 				dataData.Methods = append(dataData.Methods, &DataMethod{
 					ServiceName: nameToUpperCamelCase(s.Name),
 					MethodName:  nameToUpperCamelCase(rpc.Name),
-					ParamType:   "*" + cleanNameRequest(cleanTypeName(rpc.RequestType)),
-					ParamName:   cleanNameRequestAndToLowerCamelCase(cleanTypeName(rpc.RequestType)),
-					ReturnType:  "*" + cleanNameReply(cleanTypeName(rpc.ReturnsType)),
+					ParamType:   "*biz." + param,
+					ParamName:   toLowerCamelCase(param),
+					ReturnType:  "*biz." + result,
 					Comment:     comment,
+					Page:        base.ListPage(definition, rpc),
+					Mask:        base.UpdateMask(definition, rpc),
+					Standard:    base.StandardMethod(definition, rpc),
+					Operation:   base.Operation(s, rpc),
 				})
 			}
 		}),
 	)
-
-	// 检查并创建目标目录
-	if _, err = os.Stat(targetDir); os.IsNotExist(err) {
-		fmt.Printf("Target directory: %s does not exist, creating...\n", targetDir)
-		if err = os.MkdirAll(targetDir, 0o755); err != nil {
-			log.Fatalf("failed to create target directory: %v", err)
+	// --errors 时 Repo 方法返回映射后的业务错误
+	if errorsFile != "" {
+		if err := dataData.mapErrors(errorsFile); err != nil {
+			return nil, err
 		}
 	}
+	return dataData, nil
+}
+
+// render 渲染 data 层模板
+func render(text string, dataData any) ([]byte, error) {
+	// 加载并解析模板
+	tpl, err := template.New("dataTemplate").Funcs(template.FuncMap{
+		// elem 去掉指针类型的 * 与包名，如 *biz.User → User
+		"elem": func(s string) string { return strings.TrimPrefix(s, "*biz.") },
+
+		"toLowerCamel": toLowerCamelCase,
+		// field 返回 proto 字段对应的 biz 实体字段名，如 create_time → CreateTime
+		"field": toUpperCamelCase,
+		// model 返回资源的数据库记录类型名，如 User → userModel
+		"model": func(s string) string { return toLowerCamelCase(s) + "Model" },
 
-	// 加载并解析 data 层模板
-	tpl, err := template.New("dataTemplate").Parse(dataTemplate)
+		"gormTag":   gormTag,
+		"args":      args,
+		"fixture":   fixture,
+		"names":     names,
+		"selected":  selected,
+		"marks":     marks,
+		"sets":      sets,
+		"table":     quote,
+		"keyColumn": quote,
+		"expect":    expect,
+		"inserted":  inserted,
+		// limit、offset 为测试中 gorm 与 database/sql 的查询语句不同的部分：gorm 的 Take 带 LIMIT，偏移量为 0 时不带 OFFSET
+		"limit":  func(gorm bool) string { return map[bool]string{true: " LIMIT ?"}[gorm] },
+		"offset": func(gorm bool) string { return map[bool]string{false: " OFFSET ?"}[gorm] },
+	}).Parse(text)
 	if err != nil {
-		log.Fatalf("failed to parse data template: %v", err)
+		return nil, fmt.Errorf("failed to parse data template: %v", err)
 	}
 
-	// 生成每个服务的 Repo 实现文件
-	// 生成文件名：小写服务名 + _repo.go（kratos data 层命名规范）
-	filename := strings.ToLower(dataData.Service) + ".go"
-	targetPath := filepath.Join(targetDir, filename)
+	// 渲染模板
+	buf := new(bytes.Buffer)
+	if err := tpl.Execute(buf, dataData); err != nil {
+		return nil, fmt.Errorf("failed to render data template: %v", err)
+	}
+	return format.Source(buf.Bytes())
+}
 
-	// 跳过已存在的文件
-	if _, err = os.Stat(targetPath); !os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "data file already exists: %s\n", targetPath)
-		return
+// Resources 返回标准方法操作的资源（去重），每个资源生成一个数据库记录模型
+func (d *DataData) Resources() []*base.Resource {
+	var resources []*base.Resource
+	seen := make(map[string]bool)
+	for _, m := range d.Methods {
+		if m.Standard != nil && !seen[m.Standard.Resource.Type] {
+			seen[m.Standard.Resource.Type] = true
+			resources = append(resources, m.Standard.Resource)
+		}
 	}
+	return resources
+}
 
-	// 创建文件并渲染模板
-	file, err := os.Create(targetPath)
-	if err != nil {
-		log.Fatalf("failed to create data file: %v", err)
+// TestImports 返回测试中构造记录需要的标准库
+func (d *DataData) TestImports() []string {
+	var strs, times bool
+	for _, r := range d.Resources() {
+		for _, c := range r.Columns {
+			strs = strs || c.GoType == "string" || c.GoType == "[]byte"
+			times = times || c.GoType == "time.Time"
+		}
+	}
+	var pkgs []string
+	if strs {
+		pkgs = append(pkgs, "strconv")
+	}
+	if times {
+		pkgs = append(pkgs, "time")
+	}
+	return pkgs
+}
+
+// NotFound 报告是否有 Get、Update 或 Delete 标准方法，测试中检查记录不存在时的错误
+func (d *DataData) NotFound() bool {
+	for _, m := range d.Methods {
+		if m.Standard != nil && (m.Standard.Verb == base.VerbGet || m.Standard.Verb == base.VerbUpdate || m.Standard.Verb == base.VerbDelete) {
+			return true
+		}
+	}
+	return false
+}
+
+// Required 报告是否有从请求的字段中取资源的 Create 或 Update 方法，该字段为空时返回 BadRequest 错误
+func (d *DataData) Required() bool {
+	for _, m := range d.Methods {
+		if m.Standard != nil && m.Standard.Field != "" && (m.Standard.Verb == base.VerbCreate || m.Standard.Verb == base.VerbUpdate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Times 报告资源是否有 time.Time 类型的列
+func (d *DataData) Times() bool {
+	for _, r := range d.Resources() {
+		for _, c := range r.Columns {
+			if c.GoType == "time.Time" {
+				return true
+			}
+		}
 	}
-	defer file.Close()
+	return false
+}
 
-	if err := tpl.Execute(file, dataData); err != nil {
-		log.Fatalf("failed to render data template: %v", err)
+// Masks 返回 Update 方法的字段掩码（按资源类型去重），用于生成字段路径到数据库列的映射
+func (d *DataData) Masks() []*base.Mask {
+	var masks []*base.Mask
+	seen := make(map[string]bool)
+	for _, m := range d.Methods {
+		if m.Mask != nil && !seen[m.Mask.Type] {
+			seen[m.Mask.Type] = true
+			masks = append(masks, m.Mask)
+		}
 	}
+	return masks
+}
 
-	fmt.Printf("generated data file: %s\n", targetPath)
+// MaskColumns 返回字段掩码可以选中的列：资源的可更新列（不含主键），不是资源时为掩码类型的全部字段
+func (d *DataData) MaskColumns(mask *base.Mask) []*base.Column {
+	for _, r := range d.Resources() {
+		if r.Type == mask.Type {
+			return r.Updatable()
+		}
+	}
+	columns := make([]*base.Column, 0, len(mask.Fields))
+	for _, f := range mask.Fields {
+		columns = append(columns, &base.Column{Field: f, Name: base.ColumnName(f)})
+	}
+	return columns
 }
 
 // ------------------------------
@@ -131,15 +402,91 @@ type DataData struct {
 	Service        string        // 服务名
 	UseCasePackage string        // 领域层 UseCase 包路径
 	Methods        []*DataMethod // Repo 方法列表
+	Instrument     bool          // 是否生成 OpenTelemetry 埋点
+	Tx             bool          // Repo 是否使用 ctx 中的事务
+	DBPkg          string        // 数据库访问包
+	ErrorFunc      string        // 数据库错误的映射函数名（--errors），如 userError
+	Reason         *base.Reason  // 记录不存在对应的错误原因（--errors），如 USER_NOT_FOUND
+}
+
+// mapErrors 使用错误原因 proto 中该服务资源的 404 错误映射数据库的记录不存在错误
+func (d *DataData) mapErrors(errorsProto string) error {
+	reasons, err := base.ParseReasons(errorsProto)
+	if err != nil {
+		return err
+	}
+	resource := strings.TrimSuffix(d.Service, "Service")
+	if d.Reason = reasons.NotFound(resource); d.Reason == nil {
+		return fmt.Errorf("%s: no error reason with a 404 code to map the not found errors to", errorsProto)
+	}
+	d.ErrorFunc = toLowerCamelCase(resource) + "Error"
+	return nil
+}
+
+// Wrap 返回 Repo 方法返回的错误表达式，--errors 时经映射函数转换为业务错误
+func (d *DataData) Wrap(err string) string {
+	if d.ErrorFunc == "" {
+		return err
+	}
+	return d.ErrorFunc + "(" + err + ")"
+}
+
+// NotFoundErr 返回测试中记录不存在时 Repo 方法应返回的错误
+func (d *DataData) NotFoundErr() string {
+	switch {
+	case d.Reason != nil:
+		return "biz." + d.Reason.Var()
+	case d.Gorm():
+		return "gorm.ErrRecordNotFound"
+	default:
+		return "sql.ErrNoRows"
+	}
+}
+
+// Gorm 报告数据库访问包是否为 gorm
+func (d *DataData) Gorm() bool {
+	return d.DBPkg == dbGorm
+}
+
+// Conn 返回 Repo 方法中访问数据库的表达式，--tx 时优先使用 ctx 中的事务
+func (d *DataData) Conn() string {
+	switch {
+	case d.Tx:
+		return "r.db(ctx)"
+	case d.Gorm():
+		return "r.db.WithContext(ctx)"
+	default:
+		return "r.db"
+	}
+}
+
+// DBType 返回数据库连接的类型
+func (d *DataData) DBType() string {
+	if d.Gorm() {
+		return "*gorm.DB"
+	}
+	return "*sql.DB"
 }
 
 type DataMethod struct {
-	ServiceName string // 服务名
-	MethodName  string // 方法名
-	ParamType   string // 参数类型
-	ParamName   string // 参数名
-	ReturnType  string // 返回类型
-	Comment     string // 注释
+	ServiceName string         // 服务名
+	MethodName  string         // 方法名
+	ParamType   string         // 参数类型
+	ParamName   string         // 参数名
+	ReturnType  string         // 返回类型
+	Comment     string         // 注释
+	Page        *base.Page     // List 方法的分页字段，非分页方法为 nil
+	Mask        *base.Mask     // Update 方法的字段掩码，没有时为 nil
+	Standard    *base.Standard // 资源的标准方法（Create/Get/Update/Delete/List），其它方法为 nil
+	Operation   string         // proto 服务名/方法名（如 User/CreateUser），各层 span 的名称
+}
+
+// Resource 返回 Create 与 Update 方法入参中资源实体的表达式，如 req.User
+func (m *DataMethod) Resource() string {
+	if m.Standard.Field == "" {
+		return "req"
+	}
+	return "req." + toUpperCamelCase(m.Standard.Field)
 }
 
 // serviceName 服务名/方法名转大驼峰
@@ -162,24 +509,108 @@ func cleanTypeName(name string) string {
 	return parts[len(parts)-1]
 }
 
-func cleanNameRequestAndToLowerCamelCase(name string) string {
-	return toLowerCamelCase(strings.TrimSuffix(name, "Request"))
+// toLowerCamelCase 下划线转小驼峰（如 user_create → userCreate）
+func toLowerCamelCase(s string) string {
+	if len(s) > 0 {
+		s = strings.ToLower(s[:1]) + s[1:]
+	}
+	return s
+}
+
+// gormTag 返回列的 gorm 标签，如 `+"`gorm:\"column:id;primaryKey\"`"+`
+func gormTag(c *base.Column) string {
+	tag := "column:" + c.Name
+	if c.Field == "id" {
+		tag += ";primaryKey"
+	}
+	return "`gorm:" + strconv.Quote(tag) + "`"
 }
 
-func cleanNameRequest(name string) string {
-	return strings.TrimSuffix(name, "Request")
+// args 返回列对应的 x 的字段，如 m.Id, m.Name；x 为 &m 时返回字段的地址，用于 Scan
+func args(x string, columns []*base.Column) string {
+	var fields []string
+	for _, c := range columns {
+		fields = append(fields, x+"."+toUpperCamelCase(c.Field))
+	}
+	return strings.Join(fields, ", ")
 }
 
-func cleanNameReply(name string) string {
-	return strings.TrimSuffix(name, "Reply")
+// names 返回以逗号分隔的列名；gorm 为 true 时按 gorm 生成的 MySQL 语句加反引号，如 `+"`id`,`name`"+`
+func names(columns []*base.Column, gorm bool) string {
+	return join(columns, gorm, func(c string) string { return quote(c, gorm) })
 }
 
-// toLowerCamelCase 下划线转小驼峰（如 user_create → userCreate）
-func toLowerCamelCase(s string) string {
-	if len(s) > 0 {
-		s = strings.ToLower(s[:1]) + s[1:]
+// selected 返回查询的列，gorm 查询全部列（*）
+func selected(columns []*base.Column, gorm bool) string {
+	if gorm {
+		return "*"
+	}
+	return names(columns, false)
+}
+
+// marks 返回列对应的占位符，如 ?, ?
+func marks(columns []*base.Column, gorm bool) string {
+	return join(columns, gorm, func(string) string { return "?" })
+}
+
+// sets 返回 UPDATE 语句中列的赋值，如 name = ?, email = ?
+func sets(columns []*base.Column, gorm bool) string {
+	if gorm {
+		return join(columns, gorm, func(c string) string { return quote(c, true) + "=?" })
+	}
+	return join(columns, gorm, func(c string) string { return c + " = ?" })
+}
+
+// join 以逗号连接每列的 f(列名)，gorm 生成的语句逗号后没有空格
+func join(columns []*base.Column, gorm bool, f func(string) string) string {
+	var parts []string
+	for _, c := range columns {
+		parts = append(parts, f(c.Name))
+	}
+	if gorm {
+		return strings.Join(parts, ",")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quote 为 gorm 生成的 MySQL 语句中的表名或列名加反引号
+func quote(name string, gorm bool) string {
+	if gorm {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+// expect 返回测试中 sqlmock 预期的 SQL：database/sql 的语句完全匹配，gorm 的语句按正则匹配
+func expect(query string, gorm bool) string {
+	if gorm {
+		return "regexp.QuoteMeta(" + strconv.Quote(query) + ")"
+	}
+	return strconv.Quote(query)
+}
+
+// inserted 返回 INSERT 语句中的列：gorm 将有默认值的列（自增的整数主键）放在最后
+func inserted(r *base.Resource, gorm bool) []*base.Column {
+	if gorm && r.Key.GoType != "string" {
+		return append(slices.Clone(r.Updatable()), r.Key)
+	}
+	return r.Columns
+}
+
+// fixture 返回测试中第 n 条记录的列值，如 "name-" + strconv.Itoa(n)
+func fixture(c *base.Column) string {
+	switch c.GoType {
+	case "string":
+		return strconv.Quote(c.Field+"-") + " + strconv.Itoa(n)"
+	case "[]byte":
+		return "[]byte(" + strconv.Quote(c.Field+"-") + " + strconv.Itoa(n))"
+	case "bool":
+		return "n%2 == 1"
+	case "time.Time":
+		return "time.Date(2024, 1, n, 0, 0, 0, 0, time.UTC)"
+	default:
+		return c.GoType + "(n)"
 	}
-	return s
 }
 
 func convertToBizPackage(dataPath string) string {
@@ -5,26 +5,678 @@ package data
 
 import (
 	"context"
-
-	"github.com/go-kratos/kratos/v2/log"
+	{{- if not .Gorm }}
+	"database/sql"
+	{{- end }}
+	{{- if .Masks }}
+	"slices"
+	"strings"
+	{{- end }}
+	{{- if or .Instrument .Times }}
+	"time"
+	{{- end }}
+	{{- if or .Required .Gorm }}
+	{{ end }}
+	{{- if .Required }}
+	"github.com/go-kratos/kratos/v2/errors"
+	{{- end }}
+	{{- if .Gorm }}
+	"gorm.io/gorm"
+	{{- end }}
 
 	"{{ .UseCasePackage }}" // 依赖领域层的 Repo 接口和实体
 )
 
 // {{ .Service }}Repo 实现 biz 层定义的 {{ .Service }}Repo 接口
 type {{ .Service }}Repo struct {
-
+	{{- if .Tx }}
+	txDB // 通过 r.db(ctx) 访问数据库，ctx 中有事务时自动使用事务
+	{{- else }}
+	db {{ .DBType }} // 数据库连接
+	{{- end }}
 }
 
 // New{{ .Service }}Repo 创建 Repo 实例（依赖注入入口）
-func New{{ .Service }}Repo() biz.{{ .Service }}Repo {
-	return &{{ .Service }}Repo{}
+func New{{ .Service }}Repo(db {{ .DBType }}) biz.{{ .Service }}Repo {
+	return &{{ .Service }}Repo{ {{- if .Tx }}txDB: txDB{conn: db}{{ else }}db: db{{ end -}} }
+}
+{{- range .Resources }}
+{{ $model := model .Type }}
+// {{ $model }} {{ .Table }} 表的记录，字段为 biz.{{ .Type }} 中存储到数据库的字段
+type {{ $model }} struct {
+	{{- range .Columns }}
+	{{ field .Field }} {{ .GoType }}{{ if $.Gorm }} {{ gormTag . }}{{ end }}
+	{{- end }}
+}
+{{- if $.Gorm }}
+
+// TableName 返回 {{ $model }} 对应的表名
+func ({{ $model }}) TableName() string {
+	return "{{ .Table }}"
+}
+{{- end }}
+
+func new{{ .Type }}Model(x *biz.{{ .Type }}) *{{ $model }} {
+	return &{{ $model }}{
+		{{- range .Columns }}
+		{{ field .Field }}: x.{{ field .Field }},
+		{{- end }}
+	}
+}
+
+func (m *{{ $model }}) toBiz() *biz.{{ .Type }} {
+	return &biz.{{ .Type }}{
+		{{- range .Columns }}
+		{{ field .Field }}: m.{{ field .Field }},
+		{{- end }}
+	}
 }
+{{- end }}
 
 {{- /* 遍历方法，生成 Repo 接口实现 */ -}}
-{{- range .Methods }}
-func (r *{{ $.Service }}Repo) {{ .MethodName }}(ctx context.Context, req {{ .ParamType }}) ({{ .ReturnType }}, error) {
+{{ range .Methods }}
+
+func (r *{{ $.Service }}Repo) {{ .MethodName }}(ctx context.Context, req {{ .ParamType }}{{ if .Page }}, opts *biz.ListOptions{{ end }}) ({{ if $.Instrument }}_ {{ end }}{{ .ReturnType }}, {{ if $.Instrument }}err {{ end }}error) {
+	{{- if $.Instrument }}
+	ctx, span := tracer.Start(ctx, "{{ .Operation }}")
+	defer observe(ctx, span, "{{ .Operation }}", time.Now(), &err)
+	{{ end }}
+	{{- if .Standard }}
+	{{- $r := .Standard.Resource }}
+	{{- $key := $r.Key.Name }}
+	{{- if eq .Standard.Verb "Create" }}
+	{{- if .Standard.Field }}
+	if {{ .Resource }} == nil {
+		return nil, errors.BadRequest("VALIDATOR", "{{ .Standard.Field }}: value is required")
+	}
+	{{- end }}
+	m := new{{ $r.Type }}Model({{ .Resource }})
+	{{- if $.Gorm }}
+	if err := {{ $.Conn }}.Create(m).Error; err != nil {
+	{{- else }}
+	if _, err := {{ $.Conn }}.ExecContext(ctx, "INSERT INTO {{ $r.Table }} ({{ names $r.Columns false }}) VALUES ({{ marks $r.Columns false }})", {{ args "m" $r.Columns }}); err != nil {
+	{{- end }}
+		return nil, {{ $.Wrap "err" }}
+	}
+	return m.toBiz(), nil
+	{{- else if eq .Standard.Verb "Get" }}
+	var m {{ model $r.Type }}
+	{{- if $.Gorm }}
+	if err := {{ $.Conn }}.Take(&m, "{{ $key }} = ?", req.{{ field $r.Key.Field }}).Error; err != nil {
+	{{- else }}
+	row := {{ $.Conn }}.QueryRowContext(ctx, "SELECT {{ names $r.Columns false }} FROM {{ $r.Table }} WHERE {{ $key }} = ?", req.{{ field $r.Key.Field }})
+	if err := row.Scan({{ args "&m" $r.Columns }}); err != nil {
+	{{- end }}
+		return nil, {{ $.Wrap "err" }}
+	}
+	return m.toBiz(), nil
+	{{- else if eq .Standard.Verb "Update" }}
+	{{- if .Standard.Field }}
+	if {{ .Resource }} == nil {
+		return nil, errors.BadRequest("VALIDATOR", "{{ .Standard.Field }}: value is required")
+	}
+	{{- end }}
+	m := new{{ $r.Type }}Model({{ .Resource }})
+	{{- $masked := and .Mask (eq .Mask.Type $r.Type) }}
+	{{- if $masked }}
+	// 只更新 {{ .Mask.Name }} 选中的列，{{ .Mask.Name }} 为空时更新全部列
+	{{- if $.Gorm }}
+	columns := []string{"*"}
+	{{- else }}
+	columns := []string{ {{- range $i, $c := $r.Updatable }}{{ if $i }}, {{ end }}{{ printf "%q" $c.Name }}{{ end -}} }
+	{{- end }}
+	if len(req.{{ .Mask.Field }}) > 0 {
+		columns = {{ toLowerCamel .Mask.Type }}MaskColumns(req.{{ .Mask.Field }})
+	}
+	// 选中的字段都不是可更新的列时不执行更新
+	if len(columns) > 0 {
+		{{- if $.Gorm }}
+		if err := {{ $.Conn }}.Model(m).Select(columns).Omit("{{ $key }}").Updates(m).Error; err != nil {
+			return nil, {{ $.Wrap "err" }}
+		}
+		{{- else }}
+		values := map[string]any{
+			{{- range $r.Updatable }}
+			{{ printf "%q" .Name }}: m.{{ field .Field }},
+			{{- end }}
+		}
+		sets := make([]string, 0, len(columns))
+		args := make([]any, 0, len(columns)+1)
+		for _, c := range columns {
+			sets = append(sets, c+" = ?")
+			args = append(args, values[c])
+		}
+		args = append(args, m.{{ field $r.Key.Field }})
+		if _, err := {{ $.Conn }}.ExecContext(ctx, "UPDATE {{ $r.Table }} SET "+strings.Join(sets, ", ")+" WHERE {{ $key }} = ?", args...); err != nil {
+			return nil, {{ $.Wrap "err" }}
+		}
+		{{- end }}
+	}
+	{{- else }}
+	{{- if $.Gorm }}
+	if err := {{ $.Conn }}.Model(m).Select("*").Omit("{{ $key }}").Updates(m).Error; err != nil {
+	{{- else }}
+	if _, err := {{ $.Conn }}.ExecContext(ctx, "UPDATE {{ $r.Table }} SET {{ sets $r.Updatable false }} WHERE {{ $key }} = ?", {{ args "m" $r.Updatable }}, m.{{ field $r.Key.Field }}); err != nil {
+	{{- end }}
+		return nil, {{ $.Wrap "err" }}
+	}
+	{{- end }}
+	// 返回更新后数据库中存储的记录（含未更新的列），记录不存在时返回记录不存在的错误
+	var stored {{ model $r.Type }}
+	{{- if $.Gorm }}
+	if err := {{ $.Conn }}.Take(&stored, "{{ $key }} = ?", m.{{ field $r.Key.Field }}).Error; err != nil {
+	{{- else }}
+	row := {{ $.Conn }}.QueryRowContext(ctx, "SELECT {{ names $r.Columns false }} FROM {{ $r.Table }} WHERE {{ $key }} = ?", m.{{ field $r.Key.Field }})
+	if err := row.Scan({{ args "&stored" $r.Columns }}); err != nil {
+	{{- end }}
+		return nil, {{ $.Wrap "err" }}
+	}
+	return stored.toBiz(), nil
+	{{- else if eq .Standard.Verb "Delete" }}
+	{{- if $.Gorm }}
+	res := {{ $.Conn }}.Delete(&{{ model $r.Type }}{}, "{{ $key }} = ?", req.{{ field $r.Key.Field }})
+	if res.Error != nil {
+		return nil, {{ $.Wrap "res.Error" }}
+	}
+	if res.RowsAffected == 0 {
+		return nil, {{ $.Wrap "gorm.ErrRecordNotFound" }}
+	}
+	{{- else }}
+	res, err := {{ $.Conn }}.ExecContext(ctx, "DELETE FROM {{ $r.Table }} WHERE {{ $key }} = ?", req.{{ field $r.Key.Field }})
+	if err != nil {
+		return nil, {{ $.Wrap "err" }}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, {{ $.Wrap "err" }}
+	}
+	if n == 0 {
+		return nil, {{ $.Wrap "sql.ErrNoRows" }}
+	}
+	{{- end }}
+	return &biz.{{ .ReturnType | elem }}{}, nil
+	{{- else }}
+	{{- if .Page }}
+	// 分页查询：按 {{ $key }} 排序，最多 opts.Limit 条记录，跳过前 opts.Offset 条
+	{{- end }}
+	{{- if $.Gorm }}
+	var models []*{{ model $r.Type }}
+	if err := {{ $.Conn }}.Order("{{ $key }}"){{ if .Page }}.Offset(opts.Offset).Limit(opts.Limit){{ end }}.Find(&models).Error; err != nil {
+		return nil, {{ $.Wrap "err" }}
+	}
+	items := make([]*biz.{{ $r.Type }}, 0, len(models))
+	for _, m := range models {
+		items = append(items, m.toBiz())
+	}
+	{{- else }}
+	rows, err := {{ $.Conn }}.QueryContext(ctx, "SELECT {{ names $r.Columns false }} FROM {{ $r.Table }} ORDER BY {{ $key }}{{ if .Page }} LIMIT ? OFFSET ?", opts.Limit, opts.Offset{{ else }}"{{ end }})
+	if err != nil {
+		return nil, {{ $.Wrap "err" }}
+	}
+	defer rows.Close()
+	var items []*biz.{{ $r.Type }}
+	for rows.Next() {
+		var m {{ model $r.Type }}
+		if err := rows.Scan({{ args "&m" $r.Columns }}); err != nil {
+			return nil, {{ $.Wrap "err" }}
+		}
+		items = append(items, m.toBiz())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, {{ $.Wrap "err" }}
+	}
+	{{- end }}
+	return &biz.{{ .ReturnType | elem }}{ {{- field .Standard.Items }}: items}, nil
+	{{- end }}
+	{{- else }}
+	{{- if .Mask }}
+	// TODO: 只更新 {{ toLowerCamel .Mask.Type }}MaskColumns(req.{{ .Mask.Field }}) 返回的列
+	{{- end }}
+	{{- if .Page }}
+	// TODO: 分页查询，按唯一的列排序，最多 opts.Limit 条记录，跳过前 opts.Offset 条
+	{{- if $.Gorm }}
+	// 如 {{ $.Conn }}.Order("id").Offset(opts.Offset).Limit(opts.Limit).Find(&models)
+	{{- else }}
+	// 如 {{ $.Conn }}.QueryContext(ctx, "SELECT ... ORDER BY id LIMIT ? OFFSET ?", opts.Limit, opts.Offset)
+	{{- end }}
+	{{- end }}
 	panic("unimplemented")
+	{{- end }}
+}
+{{- end }}
+{{- range .Masks }}
+{{ $name := toLowerCamel .Type }}
+// {{ $name }}Columns {{ .Type }} 的字段路径对应的可更新的数据库列（不含主键）
+var {{ $name }}Columns = map[string]string{
+	{{- range $.MaskColumns . }}
+	{{ printf "%q" .Field }}: {{ printf "%q" .Name }},
+	{{- end }}
+}
+
+// {{ $name }}MaskColumns 返回字段掩码选中的数据库列，嵌套字段的路径（如 address.city）对应其顶层字段的列
+func {{ $name }}MaskColumns(paths []string) []string {
+	columns := make([]string, 0, len(paths))
+	for _, path := range paths {
+		field, _, _ := strings.Cut(path, ".")
+		if c, ok := {{ $name }}Columns[field]; ok && !slices.Contains(columns, c) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+{{- end }}
+`
+
+var dataTestTemplate = `{{- /* go-kratos data 层测试模板：记录 fixture + 基于 sqlmock 的标准方法测试 */ -}}
+package data
+
+import (
+	"context"
+	{{- if and .NotFound (not .Gorm) (not .Reason) }}
+	"database/sql"
+	{{- end }}
+	{{- if and .NotFound (not .Required) }}
+	"errors"
+	{{- end }}
+	"reflect"
+	{{- if .Gorm }}
+	"regexp"
+	{{- end }}
+	{{- range .TestImports }}
+	"{{ . }}"
+	{{- end }}
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	{{- if .Required }}
+	"github.com/go-kratos/kratos/v2/errors"
+	{{- end }}
+	{{- if .Gorm }}
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	{{- end }}
+
+	"{{ .UseCasePackage }}"
+)
+
+// new{{ .Service }}RepoMock 返回连接 sqlmock 数据库的 Repo，测试结束时检查预期的 SQL 是否都已执行
+func new{{ .Service }}RepoMock(t *testing.T) (biz.{{ .Service }}Repo, sqlmock.Sqlmock) {
+	t.Helper()
+	{{- if .Gorm }}
+	db, mock, err := sqlmock.New()
+	{{- else }}
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	{{- end }}
+	if err != nil {
+		t.Fatalf("open sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = db.Close()
+	})
+	{{- if .Gorm }}
+	gdb, err := gorm.Open(mysql.New(mysql.Config{Conn: db, SkipInitializeWithVersion: true}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	return New{{ .Service }}Repo(gdb), mock
+	{{- else }}
+	return New{{ .Service }}Repo(db), mock
+	{{- end }}
+}
+{{ range .Resources }}
+// new{{ .Type }}Fixture 构造第 n 条 {{ .Table }} 记录对应的实体，不同的 n 字段值不同
+func new{{ .Type }}Fixture(n int) *biz.{{ .Type }} {
+	return &biz.{{ .Type }}{
+		{{- range .Columns }}
+		{{ field .Field }}: {{ fixture . }},
+		{{- end }}
+	}
+}
+
+// new{{ .Type }}Rows 返回实体对应的 {{ .Table }} 查询结果
+func new{{ .Type }}Rows(xs ...*biz.{{ .Type }}) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{ {{- range $i, $c := .Columns }}{{ if $i }}, {{ end }}"{{ $c.Name }}"{{ end -}} })
+	for _, x := range xs {
+		rows.AddRow({{ args "x" .Columns }})
+	}
+	return rows
+}
+{{ end }}
+{{- range .Methods }}
+{{- if .Standard }}
+{{- $r := .Standard.Resource }}
+{{- $key := $r.Key.Name }}
+{{- $table := table $r.Table $.Gorm }}
+{{- if eq .Standard.Verb "Create" }}
+func Test{{ $.Service }}Repo_{{ .MethodName }}(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	want := new{{ $r.Type }}Fixture(1)
+	{{- $columns := inserted $r $.Gorm }}
+	mock.ExpectExec({{ expect (printf "INSERT INTO %s (%s) VALUES (%s)" $table (names $columns $.Gorm) (marks $columns $.Gorm)) $.Gorm }}).
+		WithArgs({{ args "want" $columns }}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	got, err := repo.{{ .MethodName }}(context.Background(), {{ if .Standard.Field }}&biz.{{ .ParamType | elem }}{ {{- field .Standard.Field }}: want}{{ else }}want{{ end }})
+	if err != nil {
+		t.Fatalf("{{ .MethodName }}() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("{{ .MethodName }}() = %+v, want %+v", got, want)
+	}
+}
+{{- if .Standard.Field }}
+
+func Test{{ $.Service }}Repo_{{ .MethodName }}_Required(t *testing.T) {
+	repo, _ := new{{ $.Service }}RepoMock(t)
+
+	// 请求中没有 {{ .Standard.Field }}，不执行 SQL
+	_, err := repo.{{ .MethodName }}(context.Background(), new(biz.{{ .ParamType | elem }}))
+	if !errors.IsBadRequest(err) {
+		t.Fatalf("{{ .MethodName }}() error = %v, want bad request", err)
+	}
+}
+{{- end }}
+{{ else if eq .Standard.Verb "Get" }}
+{{- $query := expect (printf "SELECT %s FROM %s WHERE %s = ?%s" (selected $r.Columns $.Gorm) $table $key (limit $.Gorm)) $.Gorm }}
+func Test{{ $.Service }}Repo_{{ .MethodName }}(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	want := new{{ $r.Type }}Fixture(1)
+	mock.ExpectQuery({{ $query }}).
+		WithArgs(want.{{ field $r.Key.Field }}{{ if $.Gorm }}, 1{{ end }}).
+		WillReturnRows(new{{ $r.Type }}Rows(want))
+
+	got, err := repo.{{ .MethodName }}(context.Background(), &biz.{{ .ParamType | elem }}{ {{- field $r.Key.Field }}: want.{{ field $r.Key.Field }}})
+	if err != nil {
+		t.Fatalf("{{ .MethodName }}() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("{{ .MethodName }}() = %+v, want %+v", got, want)
+	}
+}
+
+func Test{{ $.Service }}Repo_{{ .MethodName }}_NotFound(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	want := new{{ $r.Type }}Fixture(1)
+	mock.ExpectQuery({{ $query }}).
+		WithArgs(want.{{ field $r.Key.Field }}{{ if $.Gorm }}, 1{{ end }}).
+		WillReturnRows(new{{ $r.Type }}Rows())
+
+	_, err := repo.{{ .MethodName }}(context.Background(), &biz.{{ .ParamType | elem }}{ {{- field $r.Key.Field }}: want.{{ field $r.Key.Field }}})
+	if !errors.Is(err, {{ $.NotFoundErr }}) {
+		t.Fatalf("{{ .MethodName }}() error = %v, want not found", err)
+	}
+}
+{{ else if eq .Standard.Verb "Update" }}
+{{- $update := expect (printf "UPDATE %s SET %s WHERE %s = ?" $table (sets $r.Updatable $.Gorm) (keyColumn $key $.Gorm)) $.Gorm }}
+{{- $query := expect (printf "SELECT %s FROM %s WHERE %s = ?%s" (selected $r.Columns $.Gorm) $table $key (limit $.Gorm)) $.Gorm }}
+func Test{{ $.Service }}Repo_{{ .MethodName }}(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	want := new{{ $r.Type }}Fixture(1)
+	mock.ExpectExec({{ $update }}).
+		WithArgs({{ args "want" $r.Updatable }}, want.{{ field $r.Key.Field }}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery({{ $query }}).
+		WithArgs(want.{{ field $r.Key.Field }}{{ if $.Gorm }}, 1{{ end }}).
+		WillReturnRows(new{{ $r.Type }}Rows(want))
+
+	got, err := repo.{{ .MethodName }}(context.Background(), {{ if .Standard.Field }}&biz.{{ .ParamType | elem }}{ {{- field .Standard.Field }}: want}{{ else }}want{{ end }})
+	if err != nil {
+		t.Fatalf("{{ .MethodName }}() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("{{ .MethodName }}() = %+v, want %+v", got, want)
+	}
+}
+
+func Test{{ $.Service }}Repo_{{ .MethodName }}_NotFound(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	want := new{{ $r.Type }}Fixture(1)
+	mock.ExpectExec({{ $update }}).
+		WithArgs({{ args "want" $r.Updatable }}, want.{{ field $r.Key.Field }}).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery({{ $query }}).
+		WithArgs(want.{{ field $r.Key.Field }}{{ if $.Gorm }}, 1{{ end }}).
+		WillReturnRows(new{{ $r.Type }}Rows())
+
+	_, err := repo.{{ .MethodName }}(context.Background(), {{ if .Standard.Field }}&biz.{{ .ParamType | elem }}{ {{- field .Standard.Field }}: want}{{ else }}want{{ end }})
+	if !errors.Is(err, {{ $.NotFoundErr }}) {
+		t.Fatalf("{{ .MethodName }}() error = %v, want not found", err)
+	}
+}
+{{- if .Standard.Field }}
+
+func Test{{ $.Service }}Repo_{{ .MethodName }}_Required(t *testing.T) {
+	repo, _ := new{{ $.Service }}RepoMock(t)
+
+	// 请求中没有 {{ .Standard.Field }}，不执行 SQL
+	_, err := repo.{{ .MethodName }}(context.Background(), new(biz.{{ .ParamType | elem }}))
+	if !errors.IsBadRequest(err) {
+		t.Fatalf("{{ .MethodName }}() error = %v, want bad request", err)
+	}
+}
+{{- end }}
+{{- if and .Mask (eq .Mask.Type $r.Type) .Standard.Field }}
+{{- $c := index $r.Updatable 0 }}
+
+func Test{{ $.Service }}Repo_{{ .MethodName }}_Mask(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	want := new{{ $r.Type }}Fixture(1)
+	mock.ExpectExec({{ expect (printf "UPDATE %s SET %s WHERE %s = ?" $table (sets (slice $r.Updatable 0 1) $.Gorm) (keyColumn $key $.Gorm)) $.Gorm }}).
+		WithArgs(want.{{ field $c.Field }}, want.{{ field $r.Key.Field }}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery({{ $query }}).
+		WithArgs(want.{{ field $r.Key.Field }}{{ if $.Gorm }}, 1{{ end }}).
+		WillReturnRows(new{{ $r.Type }}Rows(want))
+
+	_, err := repo.{{ .MethodName }}(context.Background(), &biz.{{ .ParamType | elem }}{ {{- field .Standard.Field }}: want, {{ .Mask.Field }}: []string{ {{- printf "%q" $c.Field -}} }})
+	if err != nil {
+		t.Fatalf("{{ .MethodName }}() error = %v", err)
+	}
+}
+
+func Test{{ $.Service }}Repo_{{ .MethodName }}_MaskWithoutColumns(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	req := new{{ $r.Type }}Fixture(1)
+	want := new{{ $r.Type }}Fixture(2)
+	want.{{ field $r.Key.Field }} = req.{{ field $r.Key.Field }}
+	// 主键不可更新，不执行 UPDATE，返回数据库中存储的记录
+	mock.ExpectQuery({{ $query }}).
+		WithArgs(want.{{ field $r.Key.Field }}{{ if $.Gorm }}, 1{{ end }}).
+		WillReturnRows(new{{ $r.Type }}Rows(want))
+
+	got, err := repo.{{ .MethodName }}(context.Background(), &biz.{{ .ParamType | elem }}{ {{- field .Standard.Field }}: req, {{ .Mask.Field }}: []string{ {{- printf "%q" $r.Key.Field -}} }})
+	if err != nil {
+		t.Fatalf("{{ .MethodName }}() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("{{ .MethodName }}() = %+v, want %+v", got, want)
+	}
+}
+{{- end }}
+{{ else if eq .Standard.Verb "Delete" }}
+{{- $query := expect (printf "DELETE FROM %s WHERE %s = ?" $table $key) $.Gorm }}
+func Test{{ $.Service }}Repo_{{ .MethodName }}(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	x := new{{ $r.Type }}Fixture(1)
+	mock.ExpectExec({{ $query }}).
+		WithArgs(x.{{ field $r.Key.Field }}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	got, err := repo.{{ .MethodName }}(context.Background(), &biz.{{ .ParamType | elem }}{ {{- field $r.Key.Field }}: x.{{ field $r.Key.Field }}})
+	if err != nil {
+		t.Fatalf("{{ .MethodName }}() error = %v", err)
+	}
+	if want := new(biz.{{ .ReturnType | elem }}); !reflect.DeepEqual(got, want) {
+		t.Errorf("{{ .MethodName }}() = %+v, want %+v", got, want)
+	}
+}
+
+func Test{{ $.Service }}Repo_{{ .MethodName }}_NotFound(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	x := new{{ $r.Type }}Fixture(1)
+	mock.ExpectExec({{ $query }}).
+		WithArgs(x.{{ field $r.Key.Field }}).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err := repo.{{ .MethodName }}(context.Background(), &biz.{{ .ParamType | elem }}{ {{- field $r.Key.Field }}: x.{{ field $r.Key.Field }}})
+	if !errors.Is(err, {{ $.NotFoundErr }}) {
+		t.Fatalf("{{ .MethodName }}() error = %v, want not found", err)
+	}
+}
+{{ else }}
+func Test{{ $.Service }}Repo_{{ .MethodName }}(t *testing.T) {
+	repo, mock := new{{ $.Service }}RepoMock(t)
+	want := []*biz.{{ $r.Type }}{new{{ $r.Type }}Fixture(1), new{{ $r.Type }}Fixture(2)}
+	{{- if .Page }}
+	mock.ExpectQuery({{ expect (printf "SELECT %s FROM %s ORDER BY %s LIMIT ?%s" (selected $r.Columns $.Gorm) $table $key (offset $.Gorm)) $.Gorm }}).
+		WithArgs(10{{ if not $.Gorm }}, 0{{ end }}).
+		WillReturnRows(new{{ $r.Type }}Rows(want...))
+	{{- else }}
+	mock.ExpectQuery({{ expect (printf "SELECT %s FROM %s ORDER BY %s" (selected $r.Columns $.Gorm) $table $key) $.Gorm }}).
+		WillReturnRows(new{{ $r.Type }}Rows(want...))
+	{{- end }}
+
+	got, err := repo.{{ .MethodName }}(context.Background(), new(biz.{{ .ParamType | elem }}){{ if .Page }}, &biz.ListOptions{Limit: 10}{{ end }})
+	if err != nil {
+		t.Fatalf("{{ .MethodName }}() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.{{ field .Standard.Items }}, want) {
+		t.Errorf("{{ .MethodName }}() = %+v, want %+v", got.{{ field .Standard.Items }}, want)
+	}
+}
+{{ end }}
+{{- end }}
+{{- end }}`
+
+var dataErrorsTemplate = `{{- /* go-kratos data 层错误映射模板 */ -}}
+package data
+
+import (
+	"database/sql"
+	"errors"
+	{{- if .Gorm }}
+
+	"gorm.io/gorm"
+	{{- end }}
+
+	"{{ .UseCasePackage }}"
+)
+
+// {{ .ErrorFunc }} 将数据库错误映射为 biz 层定义的业务错误，其它错误原样返回
+func {{ .ErrorFunc }}(err error) error {
+	if errors.Is(err, sql.ErrNoRows){{ if .Gorm }} || errors.Is(err, gorm.ErrRecordNotFound){{ end }} {
+		return biz.{{ .Reason.Var }}
+	}
+	return err
+}
+`
+
+var dataTransactionTemplate = `{{- /* go-kratos data 层事务模板：实现 biz.Transaction */ -}}
+package data
+
+import (
+	"context"
+	{{- if .Gorm }}
+
+	"gorm.io/gorm"
+	{{- else }}
+	"database/sql"
+	{{- end }}
+
+	"{{ .UseCasePackage }}"
+)
+
+// txKey ctx 中事务的 key
+type txKey struct{}
+{{ if .Gorm }}
+// transaction 基于 gorm 实现 biz.Transaction
+type transaction struct {
+	db *gorm.DB
+}
+
+// NewTransaction 创建事务实例（依赖注入入口）
+func NewTransaction(db *gorm.DB) biz.Transaction {
+	return &transaction{db: db}
+}
+
+// InTx 在事务中执行 fn，事务通过 ctx 传递；fn 返回错误或 panic 时回滚
+// ctx 中已有事务时直接复用，嵌套调用不会开启新的事务
+func (t *transaction) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// txDB 嵌入 Repo 的数据库连接
+type txDB struct {
+	conn *gorm.DB
+}
+
+// db 返回 ctx 中的事务，没有事务时返回数据库连接
+func (d txDB) db(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return d.conn.WithContext(ctx)
+}
+{{- else }}
+// transaction 基于 database/sql 实现 biz.Transaction
+type transaction struct {
+	db *sql.DB
+}
+
+// NewTransaction 创建事务实例（依赖注入入口）
+func NewTransaction(db *sql.DB) biz.Transaction {
+	return &transaction{db: db}
+}
+
+// InTx 在事务中执行 fn，事务通过 ctx 传递；fn 返回错误或 panic 时回滚
+// ctx 中已有事务时直接复用，嵌套调用不会开启新的事务
+func (t *transaction) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// querier *sql.DB 与 *sql.Tx 共有的查询方法
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// txDB 嵌入 Repo 的数据库连接
+type txDB struct {
+	conn *sql.DB
+}
+
+// db 返回 ctx 中的事务，没有事务时返回数据库连接
+func (d txDB) db(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return d.conn
 }
 {{- end }}
 `
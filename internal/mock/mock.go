@@ -0,0 +1,540 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/enneket/kratos-cli-boost/internal/base"
+
+	"github.com/spf13/cobra"
+)
+
+// CmdMock represents the mock command.
+var CmdMock = &cobra.Command{
+	Use:   "mock",
+	Short: "Generate the mocks of the Repo interfaces",
+	Long:  "Generate mocks recording their calls with configurable returns for the Repo interfaces of a go file or package. Example: kratos proto mock internal/biz",
+	Run:   run,
+}
+
+var (
+	targetDir  string
+	interfaces []string
+	suffix     string
+)
+
+func init() {
+	CmdMock.Flags().StringVarP(&targetDir, "target-dir", "t", "", "generate target directory (default the mocks directory of the source package)")
+	CmdMock.Flags().StringSliceVar(&interfaces, "interface", nil, "names of the interfaces to mock (default the interfaces with the --suffix suffix)")
+	CmdMock.Flags().StringVar(&suffix, "suffix", "Repo", "suffix of the interfaces mocked by default")
+}
+
+func run(_ *cobra.Command, args []string) {
+	src := "internal/biz"
+	if len(args) > 0 {
+		src = args[0]
+	}
+	files, err := sourceFiles(src)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	dir := targetDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(files[0]), "mocks")
+	}
+	pkg, err := loadPackage(filepath.Dir(files[0]))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, file := range files {
+		mf, err := pkg.mockFile(file)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if mf == nil {
+			continue
+		}
+		b, err := mf.execute()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fmt.Println(err)
+			return
+		}
+		name := filepath.Join(dir, filepath.Base(file))
+		if err := os.WriteFile(name, b, 0o644); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("%s: %s\n", name, strings.Join(mf.names(), ", "))
+	}
+}
+
+// sourceFiles returns the go file, or the non test go files of the directory.
+func sourceFiles(src string) ([]string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{src}, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(src, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, m := range matches {
+		if !strings.HasSuffix(m, "_test.go") {
+			files = append(files, m)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no go files in %s", src)
+	}
+	return files, nil
+}
+
+// pkg is the parsed source package of the interfaces.
+type pkg struct {
+	fset  *token.FileSet
+	name  string
+	path  string // import path
+	dir   string
+	files map[string]*ast.File
+	src   map[string][]byte
+	types map[string]*ast.TypeSpec // declared types, by name
+	decls map[string]*ast.File     // files declaring the types, by name
+	names map[string]string        // package names of the imports, by import path
+}
+
+func loadPackage(dir string) (*pkg, error) {
+	path, err := base.ImportPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := &pkg{
+		fset:  token.NewFileSet(),
+		path:  path,
+		dir:   dir,
+		files: make(map[string]*ast.File),
+		src:   make(map[string][]byte),
+		types: make(map[string]*ast.TypeSpec),
+		decls: make(map[string]*ast.File),
+	}
+	files, err := sourceFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(p.fset, name, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		p.src[name] = src
+		p.name = f.Name.Name
+		p.files[name] = f
+		for _, d := range f.Decls {
+			if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, s := range gd.Specs {
+					ts := s.(*ast.TypeSpec)
+					p.types[ts.Name.Name] = ts
+					p.decls[ts.Name.Name] = f
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// mockFile returns the mocks of the interfaces declared in the file, nil if there is none.
+func (p *pkg) mockFile(name string) (*mockFile, error) {
+	f := p.files[name]
+	mf := &mockFile{imports: map[string]string{p.name: `"` + p.path + `"`, "sync": `"sync"`}}
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, s := range gd.Specs {
+			ts := s.(*ast.TypeSpec)
+			it, ok := ts.Type.(*ast.InterfaceType)
+			if !ok || !p.wanted(ts.Name.Name) {
+				continue
+			}
+			if ts.TypeParams != nil {
+				return nil, fmt.Errorf("%s: generic interface %s is not supported", name, ts.Name.Name)
+			}
+			m := &Mock{Name: ts.Name.Name, Source: p.name + "." + ts.Name.Name}
+			if err := p.addMethods(m, it, f, mf); err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", name, ts.Name.Name, err)
+			}
+			mf.Mocks = append(mf.Mocks, m)
+		}
+	}
+	if len(mf.Mocks) == 0 {
+		return nil, nil
+	}
+	return mf, nil
+}
+
+func (p *pkg) wanted(name string) bool {
+	if len(interfaces) > 0 {
+		return slices.Contains(interfaces, name)
+	}
+	return strings.HasSuffix(name, suffix) && ast.IsExported(name)
+}
+
+// addMethods adds the methods of the interface to the mock, including the ones of the embedded interfaces of the package.
+func (p *pkg) addMethods(m *Mock, it *ast.InterfaceType, f *ast.File, mf *mockFile) error {
+	for _, field := range it.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			id, ok := field.Type.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("embedded %s is not supported", p.expr(field.Type))
+			}
+			var embedded *ast.InterfaceType
+			if ts := p.types[id.Name]; ts != nil {
+				embedded, _ = ts.Type.(*ast.InterfaceType)
+			}
+			if embedded == nil {
+				return fmt.Errorf("embedded %s is not an interface of the package", id.Name)
+			}
+			// the embedded interface refers to the imports of its own file
+			if err := p.addMethods(m, embedded, p.decls[id.Name], mf); err != nil {
+				return err
+			}
+			continue
+		}
+		name := field.Names[0].Name
+		if slices.ContainsFunc(m.Methods, func(x *Method) bool { return x.Name == name }) {
+			continue
+		}
+		method := &Method{Name: name, Mock: m.Name}
+		params, err := p.fields(ft.Params, "p", f, mf)
+		if err != nil {
+			return err
+		}
+		results, err := p.fields(ft.Results, "r", f, mf)
+		if err != nil {
+			return err
+		}
+		for i, r := range results {
+			// results are named to return their zero values
+			r.Name = fmt.Sprintf("r%d", i)
+		}
+		method.Params, method.Results = params, results
+		m.Methods = append(m.Methods, method)
+	}
+	return nil
+}
+
+// fields returns the parameters or results, qualifying the types of the package.
+func (p *pkg) fields(list *ast.FieldList, prefix string, f *ast.File, mf *mockFile) ([]*Param, error) {
+	if list == nil {
+		return nil, nil
+	}
+	var params []*Param
+	for _, field := range list.List {
+		typ, err := p.qualify(field.Type, f, mf)
+		if err != nil {
+			return nil, err
+		}
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{nil}
+		}
+		for _, n := range names {
+			name := fmt.Sprintf("%s%d", prefix, len(params))
+			// m and fn are used by the generated methods
+			if n != nil && n.Name != "_" && n.Name != "m" && n.Name != "fn" {
+				name = n.Name
+			}
+			param := &Param{Name: name, Field: exported(name), Type: typ}
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				param.Variadic = true
+			}
+			params = append(params, param)
+		}
+	}
+	return params, nil
+}
+
+// qualify returns the source of the type as seen from the mocks package:
+// the types of the package are qualified with its name and the imports in use are recorded.
+func (p *pkg) qualify(typ ast.Expr, f *ast.File, mf *mockFile) (string, error) {
+	var err error
+	skip := make(map[*ast.Ident]bool)
+	ast.Inspect(typ, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			skip[n.Sel] = true
+			if x, ok := n.X.(*ast.Ident); ok {
+				skip[x] = true
+				spec := p.importSpec(f, x.Name)
+				if spec == "" {
+					err = fmt.Errorf("unknown package %s", x.Name)
+				}
+				mf.imports[x.Name] = spec
+			}
+		case *ast.Field:
+			for _, name := range n.Names {
+				skip[name] = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	last := 0
+	start, end := p.fset.Position(typ.Pos()), p.fset.Position(typ.End())
+	src, base := string(p.src[start.Filename][start.Offset:end.Offset]), start.Offset
+	ast.Inspect(typ, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || skip[id] {
+			return true
+		}
+		if _, declared := p.types[id.Name]; !declared {
+			return true
+		}
+		if !ast.IsExported(id.Name) {
+			err = fmt.Errorf("unexported type %s cannot be used from the mocks package", id.Name)
+		}
+		off := p.fset.Position(id.Pos()).Offset - base
+		b.WriteString(src[last:off])
+		b.WriteString(p.name + ".")
+		last = off
+		return true
+	})
+	b.WriteString(src[last:])
+	return b.String(), err
+}
+
+// importSpec returns the import of the file referred to as name.
+func (p *pkg) importSpec(f *ast.File, name string) string {
+	for _, i := range f.Imports {
+		if i.Name != nil {
+			if i.Name.Name == name {
+				return i.Name.Name + " " + i.Path.Value
+			}
+			continue
+		}
+		if p.packageName(strings.Trim(i.Path.Value, `"`)) == name {
+			return i.Path.Value
+		}
+	}
+	return ""
+}
+
+// packageName returns the package name of the import path.
+// The names of all the imports of the package are loaded at once with go list,
+// falling back to the name guessed from the path for the packages that cannot be loaded.
+func (p *pkg) packageName(path string) string {
+	if p.names == nil {
+		p.names = make(map[string]string)
+		var paths []string
+		for _, f := range p.files {
+			for _, i := range f.Imports {
+				if i.Name == nil {
+					paths = append(paths, strings.Trim(i.Path.Value, `"`))
+				}
+			}
+		}
+		cmd := exec.Command("go", append([]string{"list", "-e", "-f", "{{.ImportPath}} {{.Name}}"}, paths...)...)
+		cmd.Dir = p.dir
+		out, _ := cmd.Output()
+		for _, line := range strings.Split(string(out), "\n") {
+			if path, name, ok := strings.Cut(line, " "); ok && name != "" {
+				p.names[path] = name
+			}
+		}
+	}
+	if name, ok := p.names[path]; ok {
+		return name
+	}
+	return assumedName(path)
+}
+
+// assumedName returns the package name guessed from the import path like goimports,
+// e.g. yaml for gopkg.in/yaml.v3, kratos for github.com/go-kratos/kratos/v2.
+func assumedName(path string) string {
+	name := path[strings.LastIndex(path, "/")+1:]
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		if i := strings.LastIndex(path, "/"); i > 0 {
+			name = assumedName(path[:i])
+		}
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' }); i != -1 {
+		name = name[:i]
+	}
+	return name
+}
+
+func (p *pkg) expr(e ast.Expr) string {
+	var b bytes.Buffer
+	_ = printer.Fprint(&b, p.fset, e)
+	return b.String()
+}
+
+func exported(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// mockFile is a generated file of mocks.
+type mockFile struct {
+	Mocks   []*Mock
+	imports map[string]string // import specs, by package name
+}
+
+// Imports returns the sorted import specs of the file, the standard library ones first
+// and separated from the others by an empty spec.
+func (f *mockFile) Imports() []string {
+	var std, other []string
+	for _, s := range f.imports {
+		path := strings.Trim(s[strings.Index(s, `"`):], `"`)
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, s)
+		} else {
+			std = append(std, s)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	if len(other) == 0 {
+		return std
+	}
+	return append(append(std, ""), other...)
+}
+
+func (f *mockFile) names() []string {
+	var names []string
+	for _, m := range f.Mocks {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func (f *mockFile) execute() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	tmpl, err := template.New("mock").Parse(mockTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(buf, f); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// Mock is the mock of an interface.
+type Mock struct {
+	Name    string // name of the interface and of the mock
+	Source  string // qualified name of the interface, e.g. biz.UserRepo
+	Methods []*Method
+}
+
+// Method is a method of a mocked interface.
+type Method struct {
+	Mock    string
+	Name    string
+	Params  []*Param
+	Results []*Param
+}
+
+// Param is a parameter or a result of a method.
+type Param struct {
+	Name     string
+	Field    string // field name in the call record
+	Type     string
+	Variadic bool
+}
+
+// ParamList returns the parameters, e.g. ctx context.Context, user *biz.User.
+func (m *Method) ParamList() string {
+	return list(m.Params, func(p *Param) string { return p.Name + " " + p.Type })
+}
+
+// Args returns the arguments forwarding the parameters, e.g. ctx, user.
+func (m *Method) Args() string {
+	return list(m.Params, func(p *Param) string {
+		if p.Variadic {
+			return p.Name + "..."
+		}
+		return p.Name
+	})
+}
+
+// Values returns the parameters as stored in the call record, e.g. ctx, user.
+func (m *Method) Values() string {
+	return list(m.Params, func(p *Param) string { return p.Name })
+}
+
+// ResultList returns the named results, e.g. (r0 *biz.User, r1 error).
+func (m *Method) ResultList() string {
+	if len(m.Results) == 0 {
+		return ""
+	}
+	return "(" + m.ResultParams() + ")"
+}
+
+// ResultParams returns the named results as parameters, e.g. r0 *biz.User, r1 error.
+func (m *Method) ResultParams() string {
+	return list(m.Results, func(p *Param) string { return p.Name + " " + p.Type })
+}
+
+// ResultTypes returns the result types, e.g. (*biz.User, error).
+func (m *Method) ResultTypes() string {
+	if len(m.Results) == 0 {
+		return ""
+	}
+	return "(" + list(m.Results, func(p *Param) string { return p.Type }) + ")"
+}
+
+// ResultNames returns the names of the results, e.g. r0, r1.
+func (m *Method) ResultNames() string {
+	return list(m.Results, func(p *Param) string { return p.Name })
+}
+
+// FieldType returns the type of the parameter stored in the call record, variadic parameters are slices.
+func (p *Param) FieldType() string {
+	if p.Variadic {
+		return "[]" + strings.TrimPrefix(p.Type, "...")
+	}
+	return p.Type
+}
+
+func list(params []*Param, f func(*Param) string) string {
+	s := make([]string, len(params))
+	for i, p := range params {
+		s[i] = f(p)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,187 @@
+package mock
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mockModule is a module with Repo interfaces importing packages whose name differs from the last path element,
+// replaced by local stubs.
+var mockModule = map[string]string{
+	"go.mod": `module example.com/shop
+
+go 1.24
+
+require (
+	example.com/thing v0.0.0
+	github.com/go-kratos/kratos/v2 v2.0.0
+	gopkg.in/yaml.v3 v3.0.0
+)
+
+replace (
+	example.com/thing => ./stub/thing
+	github.com/go-kratos/kratos/v2 => ./stub/kratos
+	gopkg.in/yaml.v3 => ./stub/yaml
+)
+`,
+	"stub/thing/go.mod":      "module example.com/thing\n\ngo 1.24\n",
+	"stub/thing/thing.go":    "package other\n\ntype Thing struct{}\n",
+	"stub/kratos/go.mod":     "module github.com/go-kratos/kratos/v2\n\ngo 1.24\n",
+	"stub/kratos/log/log.go": "package log\n\ntype Logger interface{ Log(keyvals ...any) error }\n",
+	"stub/yaml/go.mod":       "module gopkg.in/yaml.v3\n\ngo 1.24\n",
+	"stub/yaml/yaml.go":      "package yaml\n\ntype Node struct{}\n",
+	"internal/biz/order.go": `package biz
+
+import (
+	"context"
+	stdlog "log"
+
+	"example.com/thing"
+	"github.com/go-kratos/kratos/v2/log"
+	"gopkg.in/yaml.v3"
+)
+
+type Order struct {
+	ID int64
+}
+
+type Reader interface {
+	Get(ctx context.Context, id int64) (*Order, error)
+}
+
+// OrderRepo stores the orders.
+type OrderRepo interface {
+	Reader
+	Save(ctx context.Context, orders ...*Order) ([]int64, error)
+	Load(ctx context.Context, n *yaml.Node, l log.Logger, std *stdlog.Logger) (map[string]other.Thing, error)
+	Close()
+}
+`,
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestMockFile compiles the mocks of interfaces importing packages whose name is not the last element of their path.
+func TestMockFile(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOWORK", "off")
+	root := t.TempDir()
+	writeFiles(t, root, mockModule)
+	src := filepath.Join(root, "internal", "biz")
+	p, err := loadPackage(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf, err := p.mockFile(filepath.Join(src, "order.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(mf.names(), ", "); got != "OrderRepo" {
+		t.Fatalf("mockFile() mocks = %s, want OrderRepo", got)
+	}
+	b, err := mf.execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"example.com/thing"`,
+		`"github.com/go-kratos/kratos/v2/log"`,
+		`"gopkg.in/yaml.v3"`,
+		`stdlog "log"`,
+		`"example.com/shop/internal/biz"`,
+		"func (m *OrderRepo) Get(ctx context.Context, id int64) (r0 *biz.Order, r1 error) {",
+		"map[string]other.Thing",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("mock does not contain %s", want)
+		}
+	}
+	writeFiles(t, root, map[string]string{
+		"internal/biz/mocks/order.go": string(b),
+		// the mock implements the interface
+		"internal/biz/mocks/order_test.go": "package mocks\n\nimport \"example.com/shop/internal/biz\"\n\nvar _ biz.OrderRepo = (*OrderRepo)(nil)\n",
+	})
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("mock does not compile: %v\n%s\n%s", err, out, b)
+	}
+}
+
+func TestMockFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{
+			name: "unexported type",
+			src:  "package biz\n\ntype order struct{}\n\ntype OrderRepo interface{ Get() *order }\n",
+			err:  "unexported type order cannot be used from the mocks package",
+		},
+		{
+			name: "generic interface",
+			src:  "package biz\n\ntype OrderRepo[T any] interface{ Get() T }\n",
+			err:  "generic interface OrderRepo is not supported",
+		},
+		{
+			name: "unknown package",
+			src:  "package biz\n\ntype OrderRepo interface{ Get() *yaml.Node }\n",
+			err:  "unknown package yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				"go.mod":         "module example.com/shop\n\ngo 1.24\n",
+				"biz/biz.go":     tt.src,
+				"biz/biz_doc.go": "// Package biz is the business logic.\npackage biz\n",
+			})
+			src := filepath.Join(root, "biz")
+			p, err := loadPackage(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = p.mockFile(filepath.Join(src, "biz.go"))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("mockFile() error = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAssumedName(t *testing.T) {
+	tests := map[string]string{
+		"context":                            "context",
+		"gopkg.in/yaml.v3":                   "yaml",
+		"github.com/go-kratos/kratos/v2":     "kratos",
+		"github.com/go-kratos/kratos/v2/log": "log",
+		"github.com/mattn/go-sqlite3":        "sqlite3",
+		"github.com/google/go-cmp/cmp":       "cmp",
+		"example.com/api/user/v1":            "user",
+	}
+	for path, want := range tests {
+		if got := assumedName(path); got != want {
+			t.Errorf("assumedName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package mock
+
+var mockTemplate = `// Code generated by kratos proto mock. DO NOT EDIT.
+
+package mocks
+
+import (
+{{- range .Imports }}
+	{{ . }}
+{{- end }}
+)
+{{ range .Mocks }}
+var _ {{ .Source }} = (*{{ .Name }})(nil)
+
+// {{ .Name }} is a mock of {{ .Source }}.
+// It records its calls, and returns the values of the Func fields,
+// or the zero values when they are not set.
+type {{ .Name }} struct {
+	mu sync.Mutex
+	{{- range .Methods }}
+
+	{{ .Name }}Func  func({{ .ParamList }}) {{ .ResultTypes }}
+	{{ .Name }}Calls []{{ .Mock }}{{ .Name }}Call
+	{{- end }}
+}
+{{ range .Methods }}
+// {{ .Mock }}{{ .Name }}Call is a recorded call of {{ .Mock }}.{{ .Name }}.
+type {{ .Mock }}{{ .Name }}Call struct {
+	{{- range .Params }}
+	{{ .Field }} {{ .FieldType }}
+	{{- end }}
+}
+
+// {{ .Name }} records the call and forwards it to {{ .Name }}Func.
+func (m *{{ .Mock }}) {{ .Name }}({{ .ParamList }}) {{ .ResultList }} {
+	m.mu.Lock()
+	m.{{ .Name }}Calls = append(m.{{ .Name }}Calls, {{ .Mock }}{{ .Name }}Call{ {{- .Values }}})
+	fn := m.{{ .Name }}Func
+	m.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	{{ if .Results }}return {{ end }}fn({{ .Args }})
+}
+{{- if .Results }}
+
+// {{ .Name }}Returns makes {{ .Name }} return the given values.
+func (m *{{ .Mock }}) {{ .Name }}Returns({{ .ResultParams }}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.{{ .Name }}Func = func({{ .ParamList }}) {{ .ResultTypes }} {
+		return {{ .ResultNames }}
+	}
+}
+{{- end }}
+{{ end }}
+{{- end }}`
@@ -0,0 +1,451 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/enneket/kratos-cli-boost/internal/base"
+
+	"github.com/emicklei/proto"
+)
+
+// scalarTypes are the go types of the proto scalars, the same in the pb messages and the biz entities.
+var scalarTypes = map[string]string{
+	"double":   "float64",
+	"float":    "float32",
+	"int32":    "int32",
+	"sint32":   "int32",
+	"sfixed32": "int32",
+	"int64":    "int64",
+	"sint64":   "int64",
+	"sfixed64": "int64",
+	"uint32":   "uint32",
+	"fixed32":  "uint32",
+	"uint64":   "uint64",
+	"fixed64":  "uint64",
+	"bool":     "bool",
+	"string":   "string",
+	"bytes":    "[]byte",
+}
+
+// wellKnown converts a google.protobuf type to the go type of the biz entities and back.
+type wellKnown struct {
+	bizType string
+	pbType  string
+	toBiz   string // expression of the biz value of the pb value %[1]s, empty if the value is not convertible
+	toProto string // expression of the pb value of the biz value %[1]s, empty if the value is not convertible
+	imports []string
+}
+
+// wellKnownTypes are the google.protobuf types mapped by the biz entities, see biz.wellKnownTypes.
+var wellKnownTypes = map[string]*wellKnown{
+	"google.protobuf.Timestamp": {bizType: "time.Time", pbType: "*timestamppb.Timestamp",
+		toBiz: "%[1]s.AsTime()", toProto: "timestamppb.New(%[1]s)", imports: []string{"google.golang.org/protobuf/types/known/timestamppb"}},
+	"google.protobuf.Duration": {bizType: "time.Duration", pbType: "*durationpb.Duration",
+		toBiz: "%[1]s.AsDuration()", toProto: "durationpb.New(%[1]s)", imports: []string{"google.golang.org/protobuf/types/known/durationpb"}},
+	"google.protobuf.FieldMask": {bizType: "[]string", pbType: "*fieldmaskpb.FieldMask",
+		toBiz: "%[1]s.GetPaths()", toProto: "&fieldmaskpb.FieldMask{Paths: %[1]s}", imports: []string{"google.golang.org/protobuf/types/known/fieldmaskpb"}},
+	// structpb.NewStruct fails on the values it cannot convert, the reply has to be filled in by hand
+	"google.protobuf.Struct": {bizType: "map[string]any", pbType: "*structpb.Struct",
+		toBiz: "%[1]s.AsMap()"},
+	"google.protobuf.StringValue": wrapper("string", "String"),
+	"google.protobuf.BoolValue":   wrapper("bool", "Bool"),
+	"google.protobuf.Int32Value":  wrapper("int32", "Int32"),
+	"google.protobuf.Int64Value":  wrapper("int64", "Int64"),
+	"google.protobuf.UInt32Value": wrapper("uint32", "UInt32"),
+	"google.protobuf.UInt64Value": wrapper("uint64", "UInt64"),
+	"google.protobuf.FloatValue":  wrapper("float32", "Float"),
+	"google.protobuf.DoubleValue": wrapper("float64", "Double"),
+	"google.protobuf.BytesValue": {bizType: "[]byte", pbType: "*wrapperspb.BytesValue",
+		toBiz: "%[1]s.GetValue()", toProto: "wrapperspb.Bytes(%[1]s)", imports: []string{"google.golang.org/protobuf/types/known/wrapperspb"}},
+}
+
+// wrapper returns the conversion of a wrapper type, a pointer in the biz entities.
+// Only singular wrapper fields are converted, their statements are built by singular.
+func wrapper(typ, name string) *wellKnown {
+	return &wellKnown{bizType: "*" + typ, pbType: "*wrapperspb." + name + "Value",
+		toProto: "wrapperspb." + name + "(*%[1]s)", imports: []string{"google.golang.org/protobuf/types/known/wrapperspb"}}
+}
+
+// Conversion converts a pb message to its biz entity and back.
+type Conversion struct {
+	Entity  string // biz entity, e.g. CreateUser
+	Message string // go name of the pb message, e.g. CreateUserRequest
+	Fields  []*FieldConversion
+
+	nested []*Conversion // conversions of the message fields
+}
+
+// ToBizFunc is the name of the function converting the pb message to the biz entity.
+func (c *Conversion) ToBizFunc() string {
+	return "toBiz" + c.Entity
+}
+
+// ToProtoFunc is the name of the function converting the biz entity to the pb message.
+func (c *Conversion) ToProtoFunc() string {
+	return "toProto" + c.Entity
+}
+
+// FieldConversion converts a field of a message.
+type FieldConversion struct {
+	Biz     string // go name of the biz field
+	Proto   string // go name of the pb field
+	ToBiz   string // statements setting x.Biz from the message m
+	ToProto string // statements setting m.Proto from the entity x
+
+	protoName  string
+	scalar     string      // go type of the singular scalar fields, empty for the other fields
+	message    *Conversion // conversion of the singular message fields, nil for the other fields
+	imports    []string    // imports of ToProto
+	bizImports []string    // imports of ToBiz
+}
+
+// Usage is a conversion used by a service, in one or both directions.
+type Usage struct {
+	*Conversion
+	ToBiz   bool // the service converts requests to the entity
+	ToProto bool // the service converts the entity to replies
+}
+
+// converter builds the conversions of the messages of a proto file, with the same entities and
+// fields as the biz layer: it visits the rpcs in the same order and skips the same fields.
+type converter struct {
+	messages map[string]*proto.Message // messages by name
+	enums    map[string]*proto.Enum    // enums by name
+	byName   map[string]*Conversion    // conversions by entity name
+	added    map[*proto.Message]bool   // messages whose fields were converted
+}
+
+func newConverter(definition *proto.Proto) *converter {
+	c := &converter{
+		messages: make(map[string]*proto.Message),
+		enums:    make(map[string]*proto.Enum),
+		byName:   make(map[string]*Conversion),
+		added:    make(map[*proto.Message]bool),
+	}
+	proto.Walk(definition,
+		proto.WithMessage(func(m *proto.Message) {
+			c.messages[m.Name] = m
+		}),
+		proto.WithEnum(func(e *proto.Enum) {
+			c.enums[e.Name] = e
+		}),
+	)
+	return c
+}
+
+// add returns the conversion of the entity name of the message typeName.
+// The conversion is nil if the message is not declared in the proto file, e.g. google.protobuf.Empty:
+// the biz entity has no field and the service cannot name the message.
+func (c *converter) add(name, typeName string) *Conversion {
+	if conv, ok := c.byName[name]; ok {
+		return conv
+	}
+	m := c.messages[typeName[strings.LastIndex(typeName, ".")+1:]]
+	if m == nil {
+		c.byName[name] = nil
+		return nil
+	}
+	conv := &Conversion{Entity: name, Message: goType(m)}
+	c.byName[name] = conv
+	if c.added[m] {
+		// the fields of the message belong to the entity added first, like in the biz layer
+		return conv
+	}
+	c.added[m] = true
+	seen := make(map[string]bool)
+	for _, f := range messageFields(m) {
+		name := toUpperCamelCase(f.Name)
+		if seen[name] {
+			continue
+		}
+		fc := c.field(conv, f)
+		if fc == nil {
+			continue
+		}
+		seen[name] = true
+		conv.Fields = append(conv.Fields, fc)
+	}
+	return conv
+}
+
+// element converts the values of a field type.
+type element struct {
+	bizType  string
+	pbType   string
+	toBiz    string // see wellKnown
+	toProto  string
+	imports  []string
+	known    *wellKnown
+	nested   *Conversion
+	identity bool // the pb and biz values have the same type
+}
+
+// element returns the conversion of the values of the proto type, nil if the biz layer skips it.
+func (c *converter) element(typeName string) *element {
+	if t, ok := scalarTypes[typeName]; ok {
+		return &element{bizType: t, pbType: t, toBiz: "%[1]s", toProto: "%[1]s", identity: true}
+	}
+	if w, ok := wellKnownTypes[strings.TrimPrefix(typeName, ".")]; ok {
+		return &element{bizType: w.bizType, pbType: w.pbType, toBiz: w.toBiz, toProto: w.toProto, imports: w.imports, known: w}
+	}
+	name := typeName[strings.LastIndex(typeName, ".")+1:]
+	if e, ok := c.enums[name]; ok {
+		t := "pb." + goType(e)
+		return &element{bizType: "int32", pbType: t, toBiz: "int32(%[1]s)", toProto: t + "(%[1]s)"}
+	}
+	if _, ok := c.messages[name]; ok {
+		conv := c.add(name, name)
+		if conv == nil {
+			return nil
+		}
+		return &element{bizType: "*biz." + name, pbType: "*pb." + conv.Message,
+			toBiz: conv.ToBizFunc() + "(%[1]s)", toProto: conv.ToProtoFunc() + "(%[1]s)", nested: conv}
+	}
+	return nil
+}
+
+// field returns the conversion of the field of the message of conv, nil if the biz entity has no such field.
+func (c *converter) field(conv *Conversion, f *protoField) *FieldConversion {
+	if f.KeyType != "" && scalarTypes[f.KeyType] == "" {
+		return nil
+	}
+	el := c.element(f.Type)
+	if el == nil {
+		return nil
+	}
+	fc := &FieldConversion{Biz: toUpperCamelCase(f.Name), Proto: base.GoName(f.Name), imports: el.imports, protoName: f.Name}
+	get := "m.Get" + fc.Proto + "()"
+	switch {
+	case f.KeyType != "":
+		key := scalarTypes[f.KeyType]
+		if !el.identity && strings.HasPrefix(el.bizType, "time.") {
+			fc.bizImports = []string{"time"}
+		}
+		fc.ToBiz = container(el.identity, el.toBiz, "x."+fc.Biz, get, "map["+key+"]"+el.bizType, true)
+		fc.ToProto = container(el.identity, el.toProto, "m."+fc.Proto, "x."+fc.Biz, "map["+key+"]"+el.pbType, true)
+	case f.Repeated:
+		fc.ToBiz = container(el.identity, el.toBiz, "x."+fc.Biz, get, "", false)
+		fc.ToProto = container(el.identity, el.toProto, "m."+fc.Proto, "x."+fc.Biz, "", false)
+	default:
+		fc.ToBiz, fc.ToProto = singular(el, fc, get)
+		if el.identity && el.bizType != "[]byte" {
+			fc.scalar = el.bizType
+		}
+		fc.message = el.nested
+	}
+	if el.nested != nil && !slices.Contains(conv.nested, el.nested) {
+		conv.nested = append(conv.nested, el.nested)
+	}
+	if f.oneof != nil {
+		// setting a oneof field needs its wrapper type and a choice between the set fields
+		fc.ToProto = fmt.Sprintf("// TODO: set the %s oneof from x.%s.", f.oneof.Name, fc.Biz)
+		fc.imports, fc.scalar, fc.message = nil, "", nil
+	}
+	return fc
+}
+
+// singular returns the statements converting a singular field.
+// The absent messages are left zero in the entity, and the zero values are left absent in the message.
+func singular(el *element, fc *FieldConversion, get string) (string, string) {
+	to, from := "x."+fc.Biz, "m."+fc.Proto
+	switch {
+	case el.known != nil && strings.HasPrefix(el.bizType, "*"):
+		// wrappers: a nil pointer is an absent value
+		return fmt.Sprintf("if v := %s; v != nil {\nvalue := v.GetValue()\n%s = &value\n}", get, to),
+			fmt.Sprintf("if %s != nil {\n%s = %s\n}", "x."+fc.Biz, from, fmt.Sprintf(el.toProto, "x."+fc.Biz))
+	case el.bizType == "time.Time":
+		return fmt.Sprintf("if v := %s; v != nil {\n%s = v.AsTime()\n}", get, to),
+			fmt.Sprintf("if !x.%s.IsZero() {\n%s = %s\n}", fc.Biz, from, fmt.Sprintf(el.toProto, "x."+fc.Biz))
+	case el.bizType == "time.Duration":
+		return fmt.Sprintf("%s = %s", to, fmt.Sprintf(el.toBiz, get)),
+			fmt.Sprintf("if x.%s != 0 {\n%s = %s\n}", fc.Biz, from, fmt.Sprintf(el.toProto, "x."+fc.Biz))
+	case el.known != nil && (el.bizType == "[]string" || el.bizType == "[]byte"):
+		return fmt.Sprintf("%s = %s", to, fmt.Sprintf(el.toBiz, get)),
+			fmt.Sprintf("if x.%s != nil {\n%s = %s\n}", fc.Biz, from, fmt.Sprintf(el.toProto, "x."+fc.Biz))
+	}
+	toBiz := fmt.Sprintf("%s = %s", to, fmt.Sprintf(el.toBiz, get))
+	if el.toBiz == "" {
+		toBiz = fmt.Sprintf("// TODO: set x.%s from %s.", fc.Biz, get)
+	}
+	toProto := fmt.Sprintf("%s = %s", from, fmt.Sprintf(el.toProto, "x."+fc.Biz))
+	if el.toProto == "" {
+		fc.imports = nil
+		toProto = fmt.Sprintf("// TODO: set %s from x.%s.", from, fc.Biz)
+	}
+	return toBiz, toProto
+}
+
+// container returns the statement converting the repeated or map field from into to.
+// The values are converted one by one with the expression conv, unless they have the same type.
+func container(identity bool, conv, to, from, mapType string, isMap bool) string {
+	switch {
+	case identity:
+		return fmt.Sprintf("%s = %s", to, from)
+	case conv == "", strings.Contains(conv, "*%[1]s"):
+		return fmt.Sprintf("// TODO: set %s from %s.", to, from)
+	case isMap:
+		return fmt.Sprintf("if len(%[2]s) > 0 {\n%[1]s = make(%[3]s, len(%[2]s))\n}\nfor k, v := range %[2]s {\n%[1]s[k] = %[4]s\n}",
+			to, from, mapType, fmt.Sprintf(conv, "v"))
+	}
+	return fmt.Sprintf("for _, v := range %[2]s {\n%[1]s = append(%[1]s, %[3]s)\n}", to, from, fmt.Sprintf(conv, "v"))
+}
+
+// goType returns the go name of the message or enum generated by protoc-gen-go, e.g. User_Status for
+// the Status enum nested in the User message.
+func goType(v proto.Visitee) string {
+	var name string
+	var parent proto.Visitee
+	switch t := v.(type) {
+	case *proto.Message:
+		name, parent = base.GoName(t.Name), t.Parent
+	case *proto.Enum:
+		name, parent = base.GoName(t.Name), t.Parent
+	}
+	if m, ok := parent.(*proto.Message); ok {
+		return goType(m) + "_" + name
+	}
+	return name
+}
+
+// usages returns the conversions used by the methods of the service, converting the requests to
+// entities and the entities to replies, with the conversions of their nested messages.
+func usages(methods []*Method) []*Usage {
+	byEntity := make(map[string]*Usage)
+	var use func(conv *Conversion, toBiz bool)
+	use = func(conv *Conversion, toBiz bool) {
+		if conv == nil {
+			return
+		}
+		u := byEntity[conv.Entity]
+		if u == nil {
+			u = &Usage{Conversion: conv}
+			byEntity[conv.Entity] = u
+		}
+		if (toBiz && u.ToBiz) || (!toBiz && u.ToProto) {
+			return
+		}
+		if toBiz {
+			u.ToBiz = true
+		} else {
+			u.ToProto = true
+		}
+		for _, nested := range conv.nested {
+			use(nested, toBiz)
+		}
+	}
+	for _, m := range methods {
+		if m.Type != unaryType {
+			continue
+		}
+		use(m.ParamConversion, true)
+		use(m.ResultConversion, false)
+	}
+	res := make([]*Usage, 0, len(byEntity))
+	for _, u := range byEntity {
+		res = append(res, u)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Entity < res[j].Entity })
+	return res
+}
+
+// Fixture is the value of a message set by the tests and expected in the converted entity, or the reverse:
+// its singular scalar fields, and the ones of its singular message fields.
+type Fixture struct {
+	Proto  string   // fields of the pb literal, e.g. Name: "name", User: &pb.User{Id: "id"}
+	Biz    string   // fields of the biz literal
+	Checks []*Check // expected values of the scalar fields
+}
+
+// Check is the expected value of a scalar field of a fixture.
+type Check struct {
+	Biz    string   // path of the biz field, e.g. User.Id
+	Guards []string // paths of the biz message fields holding the field, nil checked first
+	Proto  string   // getters of the pb field, e.g. GetUser().GetId()
+	Value  string
+}
+
+// fixtureDepth is the depth of the message fields set by the fixtures.
+const fixtureDepth = 2
+
+// fixture returns the fixture of the message of conv, nil if it has no scalar field to set.
+func fixture(conv *Conversion) *Fixture {
+	return fixtureOf(conv, "", "", nil, fixtureDepth, make(map[*Conversion]bool))
+}
+
+func fixtureOf(conv *Conversion, bizPath, protoPath string, guards []string, depth int, seen map[*Conversion]bool) *Fixture {
+	if conv == nil || depth < 0 || seen[conv] {
+		return nil
+	}
+	seen[conv] = true
+	defer delete(seen, conv)
+	var protoFields, bizFields []string
+	fx := &Fixture{}
+	for _, f := range conv.Fields {
+		switch {
+		case f.scalar != "":
+			value := scalarValue(f.scalar, f.protoName)
+			protoFields = append(protoFields, f.Proto+": "+value)
+			bizFields = append(bizFields, f.Biz+": "+value)
+			fx.Checks = append(fx.Checks, &Check{Biz: bizPath + f.Biz, Guards: guards, Proto: protoPath + "Get" + f.Proto + "()", Value: value})
+		case f.message != nil:
+			nested := fixtureOf(f.message, bizPath+f.Biz+".", protoPath+"Get"+f.Proto+"().",
+				append(slices.Clone(guards), bizPath+f.Biz), depth-1, seen)
+			if nested == nil {
+				continue
+			}
+			protoFields = append(protoFields, f.Proto+": &pb."+f.message.Message+"{"+nested.Proto+"}")
+			bizFields = append(bizFields, f.Biz+": &biz."+f.message.Entity+"{"+nested.Biz+"}")
+			fx.Checks = append(fx.Checks, nested.Checks...)
+		}
+	}
+	if len(fx.Checks) == 0 {
+		return nil
+	}
+	fx.Proto, fx.Biz = strings.Join(protoFields, ", "), strings.Join(bizFields, ", ")
+	return fx
+}
+
+// scalarValue returns the value of a scalar field of the fixtures: its name for the strings.
+func scalarValue(typ, name string) string {
+	switch typ {
+	case "string":
+		return strconv.Quote(name)
+	case "bool":
+		return "true"
+	case "float32", "float64":
+		return "1.5"
+	}
+	return "1"
+}
+
+// protoField is a field of a message: a normal field, a field of a oneof or a map field.
+type protoField struct {
+	*proto.Field
+	Repeated bool
+	KeyType  string       // key type of the map fields
+	oneof    *proto.Oneof // oneof of the field, if any
+}
+
+// messageFields returns the fields of the message, including the fields of its oneofs.
+func messageFields(m *proto.Message) []*protoField {
+	var fields []*protoField
+	for _, e := range m.Elements {
+		switch f := e.(type) {
+		case *proto.NormalField:
+			fields = append(fields, &protoField{Field: f.Field, Repeated: f.Repeated})
+		case *proto.MapField:
+			fields = append(fields, &protoField{Field: f.Field, KeyType: f.KeyType})
+		case *proto.Oneof:
+			for _, oe := range f.Elements {
+				if of, ok := oe.(*proto.OneOfField); ok {
+					fields = append(fields, &protoField{Field: of.Field, oneof: f})
+				}
+			}
+		}
+	}
+	return fields
+}
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/enneket/kratos-cli-boost/internal/base"
+
 	"github.com/emicklei/proto"
 	"github.com/spf13/cobra"
 	"golang.org/x/text/cases"
@@ -17,13 +19,19 @@ import (
 var CmdServer = &cobra.Command{
 	Use:   "server",
 	Short: "Generate the proto server implementations",
-	Long:  "Generate the proto server implementations. Example: kratos proto server api/xxx.proto --target-dir=internal/service",
+	Long:  "Generate the proto server implementations calling the UseCase of the biz layer next to the target directory. Example: kratos proto server api/xxx.proto --target-dir=internal/service",
 	Run:   run,
 }
-var targetDir string
+var (
+	targetDir  string
+	withTests  bool
+	instrument bool
+)
 
 func init() {
 	CmdServer.Flags().StringVarP(&targetDir, "target-dir", "t", "internal/service", "generate target directory")
+	CmdServer.Flags().BoolVar(&withTests, "with-tests", false, "also generate tests calling the unary methods through an in-memory gRPC server with a fake UseCase")
+	CmdServer.Flags().BoolVar(&instrument, "instrument", false, "start an OpenTelemetry span and record latency and error metrics in each unary method")
 }
 
 func run(_ *cobra.Command, args []string) {
@@ -31,22 +39,109 @@ func run(_ *cobra.Command, args []string) {
 		fmt.Fprintln(os.Stderr, "Please specify the proto file. Example: kratos proto server api/xxx.proto")
 		return
 	}
-	reader, err := os.Open(args[0])
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		fmt.Printf("Target directory: %s does not exist\n", targetDir)
+		return
+	}
+	changes, err := Generate(args[0], targetDir, false)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if instrument {
+		c, err := base.WriteInstrument(targetDir, "service", "service")
+		if err != nil {
+			log.Fatal(err)
+		}
+		changes = append(changes, c)
+	}
+	if withTests {
+		tests, err := GenerateTests(args[0], targetDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		changes = append(changes, tests...)
+	}
+	for _, c := range changes {
+		if c.Exists {
+			fmt.Fprintf(os.Stderr, "already exists: %s\n", c.File)
+			continue
+		}
+		fmt.Println(c.File)
+	}
+}
+
+// Generate generates the service implementations of the proto file into dir.
+// Existing files are left untouched, unless merge is set: the missing methods are then added to them.
+func Generate(protoFile, dir string, merge bool) ([]*base.Change, error) {
+	services, err := parse(protoFile, dir)
+	if err != nil {
+		return nil, err
+	}
+	var changes []*base.Change
+	for _, s := range services {
+		to := filepath.Join(dir, strings.ToLower(s.Service)+".go")
+		b, err := s.execute()
+		if err != nil {
+			return changes, err
+		}
+		c, err := base.WriteGo(to, b, merge)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// GenerateTests generates the tests of the service implementations of the proto file into dir.
+// Existing test files are left untouched.
+func GenerateTests(protoFile, dir string) ([]*base.Change, error) {
+	services, err := parse(protoFile, dir)
+	if err != nil {
+		return nil, err
+	}
+	var changes []*base.Change
+	for _, s := range services {
+		to := filepath.Join(dir, strings.ToLower(s.Service)+"_test.go")
+		b, err := s.executeTest()
+		if err != nil {
+			return changes, err
+		}
+		c, err := base.WriteGo(to, b, false)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// parse returns the services of the proto file generated into dir.
+func parse(protoFile, dir string) ([]*Service, error) {
+	reader, err := os.Open(protoFile)
+	if err != nil {
+		return nil, err
+	}
 	defer reader.Close()
 
 	parser := proto.NewParser(reader)
 	definition, err := parser.Parse()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	bizPackage, err := base.ImportPath(bizDir(dir))
+	if err != nil {
+		return nil, err
 	}
 
 	var (
 		pkg string
 		res []*Service
 	)
+	// the entities of the requests and replies are named and built like in the biz layer
+	names := base.NewEntityNames(definition)
+	converter := newConverter(definition)
 	proto.Walk(definition,
 		proto.WithOption(func(o *proto.Option) {
 			if o.Name == "go_package" {
@@ -55,41 +150,39 @@ func run(_ *cobra.Command, args []string) {
 		}),
 		proto.WithService(func(s *proto.Service) {
 			cs := &Service{
-				Package: pkg,
-				Service: serviceName(s.Name),
+				Package:    pkg,
+				BizPackage: bizPackage,
+				Service:    serviceName(s.Name),
+				Instrument: instrument,
 			}
 			for _, e := range s.Elements {
 				r, ok := e.(*proto.RPC)
 				if !ok {
 					continue
 				}
-				cs.Methods = append(cs.Methods, &Method{
+				m := &Method{
 					Service: serviceName(s.Name), Name: serviceName(r.Name), Request: parametersName(r.RequestType),
 					Reply: parametersName(r.ReturnsType), Type: getMethodType(r.StreamsRequest, r.StreamsReturns),
-				})
+					Operation: base.Operation(s, r),
+				}
+				m.Param, m.Result = names.RPC(r)
+				m.ParamConversion = converter.add(m.Param, r.RequestType)
+				m.ResultConversion = converter.add(m.Result, r.ReturnsType)
+				if m.Type == unaryType {
+					m.Mask = base.UpdateMask(definition, r)
+				}
+				cs.Methods = append(cs.Methods, m)
 			}
+			cs.Conversions = usages(cs.Methods)
 			res = append(res, cs)
 		}),
 	)
-	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
-		fmt.Printf("Target directory: %s does not exist\n", targetDir)
-		return
-	}
-	for _, s := range res {
-		to := filepath.Join(targetDir, strings.ToLower(s.Service)+".go")
-		if _, err := os.Stat(to); !os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "%s already exists: %s\n", s.Service, to)
-			continue
-		}
-		b, err := s.execute()
-		if err != nil {
-			log.Fatal(err)
-		}
-		if err := os.WriteFile(to, b, 0o644); err != nil {
-			log.Fatal(err)
-		}
-		fmt.Println(to)
-	}
+	return res, nil
+}
+
+// bizDir returns the directory of the biz layer next to the service directory, e.g. internal/biz for internal/service.
+func bizDir(dir string) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(dir)), "biz")
 }
 
 func getMethodType(streamsRequest, streamsReturns bool) MethodType {
@@ -106,6 +199,10 @@ func getMethodType(streamsRequest, streamsReturns bool) MethodType {
 }
 
 func parametersName(name string) string {
+	if strings.TrimPrefix(name, ".") == "google.protobuf.Empty" {
+		// kept qualified: the methods take and return an emptypb.Empty
+		return "google.protobuf.Empty"
+	}
 	return strings.ReplaceAll(name, ".", "_")
 }
 
@@ -118,3 +215,11 @@ func toUpperCamelCase(s string) string {
 	s = cases.Title(language.Und, cases.NoLower).String(s)
 	return strings.ReplaceAll(s, " ", "")
 }
+
+// toLowerCamelCase lowers the first letter of an upper camel case name, e.g. createUser for CreateUser.
+func toLowerCamelCase(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
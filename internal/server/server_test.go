@@ -0,0 +1,131 @@
+package server
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// streamModule is a module with a streaming service, stub pb and biz packages,
+// and a stub of the emptypb package replacing google.golang.org/protobuf.
+var streamModule = map[string]string{
+	"go.mod": `module example.com/stream
+
+go 1.24
+
+require google.golang.org/protobuf v0.0.0
+
+replace google.golang.org/protobuf => ./protobuf
+`,
+	"protobuf/go.mod":                       "module google.golang.org/protobuf\n\ngo 1.24\n",
+	"protobuf/types/known/emptypb/empty.go": "package emptypb\n\ntype Empty struct{}\n",
+	"api/stream/v1/stream.proto": `syntax = "proto3";
+
+package stream.v1;
+
+import "google/protobuf/empty.proto";
+
+option go_package = "example.com/stream/api/stream/v1;v1";
+
+service Stream {
+  rpc Unary (Req) returns (Rep);
+  rpc Bidi (stream Req) returns (stream Rep);
+  rpc Client (stream Req) returns (Rep);
+  rpc Server (Req) returns (stream Rep);
+  rpc Watch (google.protobuf.Empty) returns (stream Rep);
+}
+
+message Req {}
+message Rep {}
+`,
+	// the types generated by protoc-gen-go and protoc-gen-go-grpc
+	"api/stream/v1/stream.pb.go": `package v1
+
+import "context"
+
+type Req struct{}
+type Rep struct{}
+
+type UnimplementedStreamServer struct{}
+
+type stream interface{ Context() context.Context }
+
+type Stream_BidiServer interface {
+	stream
+	Send(*Rep) error
+	Recv() (*Req, error)
+}
+
+type Stream_ClientServer interface {
+	stream
+	SendAndClose(*Rep) error
+	Recv() (*Req, error)
+}
+
+type Stream_ServerServer interface {
+	stream
+	Send(*Rep) error
+}
+
+type Stream_WatchServer interface {
+	stream
+	Send(*Rep) error
+}
+`,
+	"internal/biz/biz.go": "package biz\n\ntype Req struct{}\n\ntype Rep struct{}\n",
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestGenerateStreaming compiles the generated streaming methods against the stub pb types.
+func TestGenerateStreaming(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	root := t.TempDir()
+	writeFiles(t, root, streamModule)
+	dir := filepath.Join(root, "internal", "service")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(filepath.Join(root, "api", "stream", "v1", "stream.proto"), dir, false); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "stream.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(b)
+	for _, want := range []string{
+		"func (s *StreamService) Bidi(conn pb.Stream_BidiServer) error {",
+		"func (s *StreamService) Client(conn pb.Stream_ClientServer) error {",
+		"func (s *StreamService) Server(req *pb.Req, conn pb.Stream_ServerServer) error {",
+		"func (s *StreamService) Watch(req *emptypb.Empty, conn pb.Stream_WatchServer) error {",
+		"case <-conn.Context().Done():",
+		"return conn.SendAndClose(&pb.Rep{})",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated service does not contain %q", want)
+		}
+	}
+
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated service does not compile: %v\n%s\n%s", err, out, src)
+	}
+}
@@ -2,7 +2,15 @@ package server
 
 import (
 	"bytes"
-	"html/template"
+	"fmt"
+	"go/format"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/enneket/kratos-cli-boost/internal/base"
 )
 
 //nolint:lll
@@ -12,37 +20,71 @@ package service
 
 
 import (
-	{{- if .UseContext }}
-	"context"
+	{{- range .StdImports }}
+	"{{ . }}"
 	{{- end }}
-	{{- if .UseIO }}
-	"io"
+{{ range .Imports }}
+	{{ . }}
 	{{- end }}
+)
 
-	pb "{{ .Package }}"
-	{{- if .GoogleEmpty }}
-	"google.golang.org/protobuf/types/known/emptypb"
+{{- $s1 := "google.protobuf.Empty" }}
+
+// {{ .Service }}UseCase is the biz logic of the {{ .Service }}Service, implemented by *biz.{{ .Service }}UseCase.
+type {{ .Service }}UseCase interface {
+	{{- range .Methods }}
+	{{- if eq .Type 1 }}
+	{{ .Name }}(ctx context.Context, {{ .ParamName }} *biz.{{ .Param }}) (*biz.{{ .Result }}, error)
 	{{- end }}
-)
+	{{- end }}
+}
 
 type {{ .Service }}Service struct {
 	pb.Unimplemented{{ .Service }}Server
-}
 
-func New{{ .Service }}Service() *{{ .Service }}Service {
-	return &{{ .Service }}Service{}
+	uc {{ .Service }}UseCase
 }
 
-{{- $s1 := "google.protobuf.Empty" }}
+func New{{ .Service }}Service(uc {{ .Service }}UseCase) *{{ .Service }}Service {
+	return &{{ .Service }}Service{uc: uc}
+}
 {{ range .Methods }}
-{{- if eq .Type 1 }}
-func (s *{{ .Service }}Service) {{ .Name }}(ctx context.Context, req {{ if eq .Request $s1 }}*emptypb.Empty{{ else }}*pb.{{ .Request }}{{ end }}) ({{ if eq .Reply $s1 }}*emptypb.Empty{{ else }}*pb.{{ .Reply }}{{ end }}, error) {
-	return {{ if eq .Reply $s1 }}&emptypb.Empty{}{{ else }}&pb.{{ .Reply }}{}{{ end }}, nil
+{{ if eq .Type 1 }}
+func (s *{{ .Service }}Service) {{ .Name }}(ctx context.Context, req {{ if eq .Request $s1 }}*emptypb.Empty{{ else }}*pb.{{ .Request }}{{ end }}) ({{ if $.Instrument }}_ {{ end }}{{ if eq .Reply $s1 }}*emptypb.Empty{{ else }}*pb.{{ .Reply }}{{ end }}, {{ if $.Instrument }}err {{ end }}error) {
+	{{- if $.Instrument }}
+	ctx, span := tracer.Start(ctx, "{{ .Operation }}")
+	defer observe(ctx, span, "{{ .Operation }}", time.Now(), &err)
+	{{ end }}
+	{{- if .Mask }}
+	if mask := req.Get{{ .Mask.Field }}(); mask != nil && !mask.IsValid(&pb.{{ .Mask.Type }}{}) {
+		return nil, errors.BadRequest("VALIDATOR", "{{ .Mask.Name }}: paths must be fields of {{ .Mask.Type }}")
+	}
+	{{- end }}
+	{{- if and (not .ParamConversion) (ne .Request $s1) }}
+	// TODO: set the biz.{{ .Param }} entity from req.
+	{{- end }}
+	{{ if .ResultConversion }}data{{ else }}_{{ end }}, err {{ if or .ResultConversion (not $.Instrument) }}:{{ end }}= s.uc.{{ .Name }}(ctx, {{ if .ParamConversion }}{{ .ParamConversion.ToBizFunc }}(req){{ else }}&biz.{{ .Param }}{}{{ end }})
+	if err != nil {
+		return nil, err
+	}
+	{{- if .ResultConversion }}
+	return {{ .ResultConversion.ToProtoFunc }}(data), nil
+	{{- else if eq .Reply $s1 }}
+	return &emptypb.Empty{}, nil
+	{{- else }}
+	// TODO: fill in the reply from the biz.{{ .Result }} entity.
+	return &pb.{{ .Reply }}{}, nil
+	{{- end }}
 }
 
 {{- else if eq .Type 2 }}
 func (s *{{ .Service }}Service) {{ .Name }}(conn pb.{{ .Service }}_{{ .Name }}Server) error {
 	for {
+		select {
+		case <-conn.Context().Done():
+			return conn.Context().Err()
+		default:
+		}
 		req, err := conn.Recv()
 		if err == io.EOF {
 			return nil
@@ -50,9 +92,9 @@ func (s *{{ .Service }}Service) {{ .Name }}(conn pb.{{ .Service }}_{{ .Name }}Se
 		if err != nil {
 			return err
 		}
-		
-		err = conn.Send(&pb.{{ .Reply }}{})
-		if err != nil {
+		// TODO: handle req and fill in the reply.
+		_ = req
+		if err := conn.Send(&pb.{{ .Reply }}{}); err != nil {
 			return err
 		}
 	}
@@ -61,29 +103,204 @@ func (s *{{ .Service }}Service) {{ .Name }}(conn pb.{{ .Service }}_{{ .Name }}Se
 {{- else if eq .Type 3 }}
 func (s *{{ .Service }}Service) {{ .Name }}(conn pb.{{ .Service }}_{{ .Name }}Server) error {
 	for {
+		select {
+		case <-conn.Context().Done():
+			return conn.Context().Err()
+		default:
+		}
 		req, err := conn.Recv()
 		if err == io.EOF {
-			return conn.SendAndClose(&pb.{{ .Reply }}{})
+			break
 		}
 		if err != nil {
 			return err
 		}
+		// TODO: accumulate req into the reply.
+		_ = req
 	}
+	return conn.SendAndClose(&pb.{{ .Reply }}{})
 }
 
 {{- else if eq .Type 4 }}
-func (s *{{ .Service }}Service) {{ .Name }}(req {{ if eq .Request $s1 }}*emptypb.Empty
-{{ else }}*pb.{{ .Request }}{{ end }}, conn pb.{{ .Service }}_{{ .Name }}Server) error {
-	for {
-		err := conn.Send(&pb.{{ .Reply }}{})
-		if err != nil {
+func (s *{{ .Service }}Service) {{ .Name }}(req {{ if eq .Request $s1 }}*emptypb.Empty{{ else }}*pb.{{ .Request }}{{ end }}, conn pb.{{ .Service }}_{{ .Name }}Server) error {
+	// TODO: build the replies to stream for req.
+	_ = req
+	var replies []*pb.{{ .Reply }}
+	for _, reply := range replies {
+		select {
+		case <-conn.Context().Done():
+			return conn.Context().Err()
+		default:
+		}
+		if err := conn.Send(reply); err != nil {
 			return err
 		}
 	}
+	return nil
 }
 
 {{- end }}
 {{- end }}
+{{ range .Conversions }}
+{{- if .ToBiz }}
+// {{ .ToBizFunc }} converts the pb.{{ .Message }} message to the biz.{{ .Entity }} entity.
+func {{ .ToBizFunc }}(m *pb.{{ .Message }}) *biz.{{ .Entity }} {
+	if m == nil {
+		return nil
+	}
+	x := &biz.{{ .Entity }}{}
+	{{- range .Fields }}
+	{{ .ToBiz }}
+	{{- end }}
+	return x
+}
+{{ end }}
+{{- if .ToProto }}
+// {{ .ToProtoFunc }} converts the biz.{{ .Entity }} entity to the pb.{{ .Message }} message.
+func {{ .ToProtoFunc }}(x *biz.{{ .Entity }}) *pb.{{ .Message }} {
+	if x == nil {
+		return nil
+	}
+	m := &pb.{{ .Message }}{}
+	{{- range .Fields }}
+	{{ .ToProto }}
+	{{- end }}
+	return m
+}
+{{ end }}
+{{- end }}
+`
+
+//nolint:lll
+var serviceTestTemplate = `
+{{- /* delete empty line */ -}}
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	{{- if .MaskFixtures }}
+	"slices"
+	{{- end }}
+	"testing"
+
+	"{{ .BizPackage }}"
+	pb "{{ .Package }}"
+	{{- if .UnaryEmptyRequest }}
+	"google.golang.org/protobuf/types/known/emptypb"
+	{{- end }}
+	{{- if .MaskFixtures }}
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	{{- end }}
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+{{- $s1 := "google.protobuf.Empty" }}
+
+// the UseCase of the biz layer is the one the service depends on
+var _ {{ .Service }}UseCase = (*biz.{{ .Service }}UseCase)(nil)
+
+// fake{{ .Service }}UseCase implements {{ .Service }}UseCase, the behavior of each method is set by its function field.
+type fake{{ .Service }}UseCase struct {
+	{{- range .Methods }}
+	{{- if eq .Type 1 }}
+	{{ .Name }}Func func(ctx context.Context, {{ .ParamName }} *biz.{{ .Param }}) (*biz.{{ .Result }}, error)
+	{{- end }}
+	{{- end }}
+}
+{{ range .Methods }}
+{{- if eq .Type 1 }}
+func (f *fake{{ $.Service }}UseCase) {{ .Name }}(ctx context.Context, {{ .ParamName }} *biz.{{ .Param }}) (*biz.{{ .Result }}, error) {
+	if f.{{ .Name }}Func == nil {
+		panic("unexpected call to {{ $.Service }}UseCase.{{ .Name }}")
+	}
+	return f.{{ .Name }}Func(ctx, {{ .ParamName }})
+}
+{{ end }}
+{{- end }}
+
+// new{{ .Service }}Client starts the {{ .Service }}Service of the UseCase on an in-memory listener and returns a client connected to it.
+func new{{ .Service }}Client(t *testing.T, uc {{ .Service }}UseCase) pb.{{ .Service }}Client {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.Register{{ .Service }}Server(srv, New{{ .Service }}Service(uc))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial bufnet: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.New{{ .Service }}Client(conn)
+}
+{{ range .Methods }}
+{{- if eq .Type 1 }}
+{{- $param := .ParamName }}
+func Test{{ .Service }}Service_{{ .Name }}(t *testing.T) {
+	errUseCase := errors.New("usecase failed")
+	tests := []struct {
+		name    string
+		reply   *biz.{{ .Result }}
+		ucErr   error
+		wantErr bool
+	}{
+		{name: "success", reply: &biz.{{ .Result }}{ {{- with .ReplyFixture }}{{ .Biz }}{{ end -}} }},
+		{name: "usecase error", ucErr: errUseCase, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := new{{ .Service }}Client(t, &fake{{ .Service }}UseCase{
+				{{ .Name }}Func: func(ctx context.Context, {{ .ParamName }} *biz.{{ .Param }}) (*biz.{{ .Result }}, error) {
+					{{- with .RequestFixture }}
+					{{- range .Checks }}
+					if {{ range .Guards }}{{ $param }}.{{ . }} == nil || {{ end }}{{ $param }}.{{ .Biz }} != {{ .Value }} {
+						t.Errorf("{{ $param }}.{{ .Biz }} = %v, want %v", {{ $param }}.{{ .Biz }}, {{ .Value }})
+					}
+					{{- end }}
+					{{- end }}
+					{{- with .MaskFixture }}
+					if !slices.Equal({{ $param }}.{{ .Field }}, []string{ {{- printf "%q" .Path -}} }) {
+						t.Errorf("{{ $param }}.{{ .Field }} = %v, want [{{ .Path }}]", {{ $param }}.{{ .Field }})
+					}
+					{{- end }}
+					return tt.reply, tt.ucErr
+				},
+			})
+
+			reply, err := client.{{ .Name }}(context.Background(), {{ if eq .Request $s1 }}&emptypb.Empty{}{{ else }}&pb.{{ .Request }}{ {{- .RequestLiteral -}} }{{ end }})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("{{ .Name }}() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if reply == nil {
+				t.Fatal("{{ .Name }}() reply is nil")
+			}
+			{{- with .ReplyFixture }}
+			{{- range .Checks }}
+			if got := reply.{{ .Proto }}; got != {{ .Value }} {
+				t.Errorf("{{ .Proto }} = %v, want %v", got, {{ .Value }})
+			}
+			{{- end }}
+			{{- end }}
+		})
+	}
+}
+{{ end }}
+{{- end }}
 `
 
 type MethodType uint8
@@ -101,9 +318,15 @@ type Service struct {
 	Service     string
 	Methods     []*Method
 	GoogleEmpty bool
+	// BizPackage is the import path of the biz layer implementing the UseCase of the service.
+	BizPackage string
+	// Conversions convert the requests to the biz entities and the entities to the replies.
+	Conversions []*Usage
 
 	UseIO      bool
 	UseContext bool
+	// Instrument starts an OpenTelemetry span and records metrics in the unary methods.
+	Instrument bool
 }
 
 // Method is a proto method.
@@ -113,11 +336,159 @@ type Method struct {
 	Request string
 	Reply   string
 
+	// Operation is the proto Service/Method, the span name of the method in all layers.
+	Operation string
+	// Param and Result are the biz entities of the request and the reply.
+	Param  string
+	Result string
+	// ParamConversion and ResultConversion convert the request and the reply,
+	// nil if the message is not declared in the proto file.
+	ParamConversion  *Conversion
+	ResultConversion *Conversion
+
 	// type: unary or stream
 	Type MethodType
+	// Mask is the field mask of the unary Update methods, nil for the other methods.
+	Mask *base.Mask
+}
+
+// ParamName is the name of the UseCase parameter, like in the biz layer.
+func (m *Method) ParamName() string {
+	return toLowerCamelCase(m.Param)
+}
+
+// RequestFixture is the request sent by the test of the method, nil if there is no field to set.
+func (m *Method) RequestFixture() *Fixture {
+	return fixture(m.ParamConversion)
+}
+
+// ReplyFixture is the entity returned by the fake UseCase in the test of the method, nil if there is no field to set.
+func (m *Method) ReplyFixture() *Fixture {
+	return fixture(m.ResultConversion)
+}
+
+// MaskFixture is a field mask path sent by the test of the method and expected in the entity.
+type MaskFixture struct {
+	Field string // go name of the mask field, the same in the request and the entity
+	Path  string
+}
+
+// MaskFixture returns the field mask sent by the test of the Update method, nil for the other methods.
+func (m *Method) MaskFixture() *MaskFixture {
+	if m.Mask == nil || m.ParamConversion == nil || len(m.Mask.Paths) == 0 {
+		return nil
+	}
+	return &MaskFixture{Field: m.Mask.Field, Path: m.Mask.Paths[0]}
+}
+
+// RequestLiteral returns the fields of the request sent by the test of the method.
+func (m *Method) RequestLiteral() string {
+	var fields []string
+	if f := m.RequestFixture(); f != nil {
+		fields = append(fields, f.Proto)
+	}
+	if f := m.MaskFixture(); f != nil {
+		fields = append(fields, fmt.Sprintf("%s: &fieldmaskpb.FieldMask{Paths: []string{%q}}", f.Field, f.Path))
+	}
+	return strings.Join(fields, ", ")
+}
+
+// Masked reports whether a method of the service is an Update method with a field mask.
+func (s *Service) Masked() bool {
+	for _, method := range s.Methods {
+		if method.Mask != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskFixtures reports whether a test of the service sends a field mask.
+func (s *Service) MaskFixtures() bool {
+	for _, method := range s.Methods {
+		if method.Type == unaryType && method.MaskFixture() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// StdImports returns the standard library imports of the service.
+func (s *Service) StdImports() []string {
+	var imports []string
+	if s.UseContext {
+		imports = append(imports, "context")
+	}
+	if s.UseIO {
+		imports = append(imports, "io")
+	}
+	if s.Instrument && s.UseContext {
+		imports = append(imports, "time")
+	}
+	return s.conversionImports(imports, true)
+}
+
+// Imports returns the other import specs of the service.
+func (s *Service) Imports() []string {
+	var imports []string
+	if s.Masked() {
+		imports = append(imports, `"github.com/go-kratos/kratos/v2/errors"`)
+	}
+	if s.UseContext {
+		imports = append(imports, strconv.Quote(s.BizPackage))
+	}
+	imports = append(imports, "pb "+strconv.Quote(s.Package))
+	if s.GoogleEmpty {
+		imports = append(imports, `"google.golang.org/protobuf/types/known/emptypb"`)
+	}
+	for _, imp := range s.conversionImports(nil, false) {
+		imports = append(imports, strconv.Quote(imp))
+	}
+	return imports
+}
+
+// conversionImports adds to imports the standard library or the other imports of the conversions.
+func (s *Service) conversionImports(imports []string, std bool) []string {
+	for _, u := range s.Conversions {
+		for _, f := range u.Fields {
+			var used []string
+			if u.ToBiz {
+				used = append(used, f.bizImports...)
+			}
+			if u.ToProto {
+				used = append(used, f.imports...)
+			}
+			for _, imp := range used {
+				if !slices.Contains(imports, imp) && !strings.Contains(imp, ".") == std {
+					imports = append(imports, imp)
+				}
+			}
+		}
+	}
+	sort.Strings(imports)
+	return imports
 }
 
 func (s *Service) execute() ([]byte, error) {
+	return s.render(serviceTemplate)
+}
+
+// executeTest renders the tests of the unary methods against an in-process gRPC server.
+func (s *Service) executeTest() ([]byte, error) {
+	return s.render(serviceTestTemplate)
+}
+
+// UnaryEmptyRequest reports whether a unary method takes a google.protobuf.Empty request.
+func (s *Service) UnaryEmptyRequest() bool {
+	for _, method := range s.Methods {
+		if method.Type == unaryType && method.Request == "google.protobuf.Empty" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) render(text string) ([]byte, error) {
 	const empty = "google.protobuf.Empty"
 	buf := new(bytes.Buffer)
 	for _, method := range s.Methods {
@@ -132,12 +503,12 @@ func (s *Service) execute() ([]byte, error) {
 			s.UseContext = true
 		}
 	}
-	tmpl, err := template.New("service").Parse(serviceTemplate)
+	tmpl, err := template.New("service").Parse(text)
 	if err != nil {
 		return nil, err
 	}
 	if err := tmpl.Execute(buf, s); err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return format.Source(buf.Bytes())
 }
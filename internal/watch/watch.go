@@ -0,0 +1,205 @@
+package watch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/enneket/kratos-cli-boost/internal/base"
+	"github.com/enneket/kratos-cli-boost/internal/biz"
+	"github.com/enneket/kratos-cli-boost/internal/client"
+	"github.com/enneket/kratos-cli-boost/internal/data"
+	"github.com/enneket/kratos-cli-boost/internal/server"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// CmdWatch represents the watch command.
+var CmdWatch = &cobra.Command{
+	Use:   "watch",
+	Short: "Regenerate the code when the proto files change",
+	Long: "Watch the proto files and regenerate the client code and update the server, biz and data layers when they change. " +
+		"The layers are only updated by merging the stubs of the new methods into the existing files, " +
+		"generated with the --instrument, --tx, --db-pkg and --errors flags used with the layer commands. Example: kratos proto watch api --tx --db-pkg=gorm.io/gorm",
+	Run: run,
+}
+
+var (
+	debounce  time.Duration
+	serverDir string
+	bizDir    string
+	dataDir   string
+)
+
+// layerFlags are the flags of the layer commands accepted by watch and passed to the layers having them.
+var layerFlags = []string{"instrument", "tx", "db-pkg", "errors"}
+
+func init() {
+	CmdWatch.Flags().DurationVar(&debounce, "debounce", 300*time.Millisecond, "wait for the writes to settle for this long before regenerating")
+	CmdWatch.Flags().StringVar(&serverDir, "server-dir", "internal/service", "server layer directory, skipped if it does not exist")
+	CmdWatch.Flags().StringVar(&bizDir, "biz-dir", "internal/biz", "biz layer directory, skipped if it does not exist")
+	CmdWatch.Flags().StringVar(&dataDir, "data-dir", "internal/data", "data layer directory, skipped if it does not exist")
+	CmdWatch.Flags().Bool("instrument", false, "merge the methods with the OpenTelemetry spans and metrics of the server, biz and data layers")
+	CmdWatch.Flags().Bool("tx", false, "merge the biz and data methods using the transaction carried by the context")
+	CmdWatch.Flags().String("db-pkg", "database/sql", "database package of the data layer: database/sql or gorm.io/gorm")
+	CmdWatch.Flags().String("errors", "", "error reasons proto file the data methods map the database errors with")
+}
+
+// setLayerFlags sets the flags of the layer commands, read by their generators, to the layer flags given to watch.
+func setLayerFlags(cmd *cobra.Command) error {
+	for _, name := range layerFlags {
+		f := cmd.Flags().Lookup(name)
+		if !f.Changed {
+			continue
+		}
+		for _, layer := range []*cobra.Command{server.CmdServer, biz.CmdBiz, data.CmdData} {
+			if layer.Flags().Lookup(name) == nil {
+				continue
+			}
+			if err := layer.Flags().Set(name, f.Value.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func run(cmd *cobra.Command, args []string) {
+	dir := "api"
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if err := setLayerFlags(cmd); err != nil {
+		fmt.Println(err)
+		return
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer w.Close()
+	if err := addDirs(w, dir); err != nil {
+		fmt.Println(err)
+		return
+	}
+	// bring the generated code up to date before waiting for changes
+	if err := client.Generate(dir); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Printf("watching %s, press Ctrl+C to stop\n", dir)
+
+	var (
+		changed = make(map[string]bool)
+		timer   = time.NewTimer(debounce)
+	)
+	timer.Stop()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					// new directories are not watched by the parent watch
+					if err := addDirs(w, ev.Name); err != nil {
+						fmt.Println(err)
+					}
+					continue
+				}
+			}
+			if filepath.Ext(ev.Name) != ".proto" || ev.Op == fsnotify.Chmod {
+				continue
+			}
+			changed[ev.Name] = true
+			timer.Reset(debounce)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			fmt.Println(err)
+		case <-timer.C:
+			update(dir, changed)
+			changed = make(map[string]bool)
+		}
+	}
+}
+
+// addDirs watches the directory and its subdirectories, skipping the hidden ones.
+func addDirs(w *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
+
+// update regenerates the code of the changed proto files and prints a report of the changes.
+func update(dir string, changed map[string]bool) {
+	protos := make([]string, 0, len(changed))
+	for p := range changed {
+		protos = append(protos, p)
+	}
+	sort.Strings(protos)
+	fmt.Printf("\n%s changed: %s\n", time.Now().Format(time.TimeOnly), strings.Join(protos, " "))
+
+	// the lock file lets the client generation also catch the protos importing the changed ones
+	if err := client.Generate(dir); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, p := range protos {
+		if _, err := os.Stat(p); err != nil {
+			// removed or renamed, the generated code is left for the developer to clean up
+			continue
+		}
+		if exists(serverDir) {
+			changes, err := server.Generate(p, serverDir, true)
+			report(changes, err)
+		}
+		if exists(bizDir) {
+			c, err := biz.Generate(p, bizDir, true)
+			changes := []*base.Change{c}
+			if err == nil {
+				c, err = biz.GeneratePagination(p, bizDir)
+				changes = append(changes, c)
+			}
+			report(changes, err)
+		}
+		if exists(dataDir) {
+			c, err := data.Generate(p, dataDir, true)
+			report([]*base.Change{c}, err)
+		}
+	}
+}
+
+func report(changes []*base.Change, err error) {
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, c := range changes {
+		switch {
+		case c == nil || c.Exists:
+		case len(c.Added) == 0:
+			fmt.Printf("created %s\n", c.File)
+		default:
+			fmt.Printf("updated %s: +%s\n", c.File, strings.Join(c.Added, " +"))
+		}
+	}
+}
+
+func exists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}